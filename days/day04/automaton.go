@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// UpdateMode controls whether a generation reads the previous state or the state being written
+type UpdateMode int
+
+const (
+	// Synchronous computes every cell from the previous generation, then applies all changes at once
+	Synchronous UpdateMode = iota
+	// Asynchronous updates cells in place in scan order, so later cells see earlier changes
+	Asynchronous
+)
+
+// ParseUpdateMode converts "sync" or "async" into an UpdateMode
+func ParseUpdateMode(s string) (UpdateMode, error) {
+	switch strings.ToLower(s) {
+	case "sync", "synchronous":
+		return Synchronous, nil
+	case "async", "asynchronous":
+		return Asynchronous, nil
+	}
+	return Synchronous, fmt.Errorf("unknown update mode %q: must be 'sync' or 'async'", s)
+}
+
+// String returns the rule file spelling of the update mode
+func (m UpdateMode) String() string {
+	if m == Asynchronous {
+		return "async"
+	}
+	return "sync"
+}
+
+// Transition turns a From cell into To when its count of Counted neighbors satisfies
+// Comparison against Threshold, e.g. "@ < 4 -> ." or ". == 3 @ -> @"
+type Transition struct {
+	From       rune
+	Counted    rune
+	Comparison Comparison
+	Threshold  int
+	To         rune
+}
+
+// String returns the rule file spelling of the transition
+func (t Transition) String() string {
+	if t.Counted == t.From {
+		return fmt.Sprintf("%c %s %d -> %c", t.From, t.Comparison, t.Threshold, t.To)
+	}
+	return fmt.Sprintf("%c %s %d %c -> %c", t.From, t.Comparison, t.Threshold, t.Counted, t.To)
+}
+
+// Automaton is a cellular automaton over a Grid. For each cell the first Transition
+// whose From matches the cell and whose neighbor count test passes is applied.
+type Automaton struct {
+	Neighborhood   Neighborhood
+	Transitions    []Transition
+	Update         UpdateMode
+	MaxGenerations int // 0 means run until stable or cycling
+}
+
+// Change records a single cell changing during a generation
+type Change struct {
+	Pos  Position
+	From rune
+	To   rune
+}
+
+// GenerationFunc receives the changes made in a generation (generations are 1-based),
+// in the same bottom-to-top, left-to-right order as FindSelectedPositions
+type GenerationFunc func(generation int, changes []Change)
+
+// StopReason explains why Automaton.Run stopped
+type StopReason int
+
+const (
+	StopStable StopReason = iota
+	StopMaxGenerations
+	StopCycle
+)
+
+// String describes the stop reason
+func (r StopReason) String() string {
+	switch r {
+	case StopMaxGenerations:
+		return "max generations reached"
+	case StopCycle:
+		return "cycle detected"
+	}
+	return "stable"
+}
+
+// RunResult summarizes an Automaton run
+type RunResult struct {
+	Generations int // generations that changed at least one cell
+	Changes     int // total cell changes across all generations
+	Reason      StopReason
+	CycleStart  int // generation whose state recurred, when Reason is StopCycle (0 is the input)
+	CyclePeriod int // generations between repeats, when Reason is StopCycle
+	Grid        *Grid
+}
+
+// RemovalAutomaton expresses a selection Rule as an automaton that turns selected cells into '.'
+func RemovalAutomaton(rule Rule, maxGenerations int) *Automaton {
+	return &Automaton{
+		Neighborhood: rule.Neighborhood,
+		Transitions: []Transition{{
+			From:       rule.Symbol,
+			Counted:    rule.Symbol,
+			Comparison: rule.Comparison,
+			Threshold:  rule.Threshold,
+			To:         '.',
+		}},
+		Update:         Synchronous,
+		MaxGenerations: maxGenerations,
+	}
+}
+
+// Presets are the built-in automata, named after the original modes
+var Presets = map[string]func(rule Rule) *Automaton{
+	// initial removes the selected cells once
+	"initial": func(rule Rule) *Automaton { return RemovalAutomaton(rule, 1) },
+	// completion keeps removing selected cells until none remain
+	"completion": func(rule Rule) *Automaton { return RemovalAutomaton(rule, 0) },
+}
+
+// LoadAutomaton reads an automaton from a rule file
+func LoadAutomaton(path string) (*Automaton, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseAutomaton(file)
+}
+
+// ParseAutomaton reads a rule file. Each non-empty line not starting with '#' is either
+// a directive or a transition:
+//
+//	neighborhood moore:1    neighborhood spec, as for -neighborhood (default moore)
+//	update sync             'sync' or 'async' (default sync)
+//	max-generations 100     generation limit, 0 for none (default 0)
+//	alive @                 live symbol used by B/S notation (default '@')
+//	dead .                  dead symbol used by B/S notation (default '.')
+//	B3/S23                  birth/survival counts of live neighbors
+//	@ < 4 -> .              from, comparison, threshold, optional counted symbol, to
+func ParseAutomaton(r io.Reader) (*Automaton, error) {
+	a := &Automaton{Neighborhood: MooreNeighborhood(1), Update: Synchronous}
+	alive, dead := '@', '.'
+	var birthSurvival string
+
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		var err error
+		switch strings.ToLower(fields[0]) {
+		case "neighborhood":
+			if len(fields) != 2 {
+				err = fmt.Errorf("expected 'neighborhood <spec>'")
+			} else {
+				a.Neighborhood, err = ParseNeighborhood(fields[1])
+			}
+		case "update":
+			if len(fields) != 2 {
+				err = fmt.Errorf("expected 'update sync|async'")
+			} else {
+				a.Update, err = ParseUpdateMode(fields[1])
+			}
+		case "max-generations":
+			if len(fields) != 2 {
+				err = fmt.Errorf("expected 'max-generations <n>'")
+			} else if a.MaxGenerations, err = strconv.Atoi(fields[1]); err != nil || a.MaxGenerations < 0 {
+				err = fmt.Errorf("invalid generation limit %q", fields[1])
+			}
+		case "alive", "dead":
+			var symbol rune
+			if len(fields) != 2 {
+				err = fmt.Errorf("expected '%s <symbol>'", fields[0])
+			} else if symbol, err = ParseSymbol(fields[1]); err == nil {
+				if strings.ToLower(fields[0]) == "alive" {
+					alive = symbol
+				} else {
+					dead = symbol
+				}
+			}
+		default:
+			if isBirthSurvival(fields[0]) && len(fields) == 1 {
+				birthSurvival = fields[0]
+				continue
+			}
+			var t Transition
+			if t, err = parseTransition(fields); err == nil {
+				a.Transitions = append(a.Transitions, t)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// B/S notation depends on the final neighborhood size, so expand it last
+	if birthSurvival != "" {
+		a.Transitions = append(a.Transitions, expandBirthSurvival(birthSurvival, alive, dead, len(a.Neighborhood))...)
+	}
+	if len(a.Transitions) == 0 {
+		return nil, fmt.Errorf("rule file declares no transitions")
+	}
+
+	return a, nil
+}
+
+// parseTransition parses "<from> <op> <n> [counted] -> <to>"
+func parseTransition(fields []string) (Transition, error) {
+	var t Transition
+	if len(fields) < 5 || len(fields) > 6 || fields[len(fields)-2] != "->" {
+		return t, fmt.Errorf("expected '<from> <op> <n> [counted] -> <to>', got %q", strings.Join(fields, " "))
+	}
+
+	var err error
+	if t.From, err = ParseSymbol(fields[0]); err != nil {
+		return t, err
+	}
+	if t.Comparison, err = ParseComparison(fields[1]); err != nil {
+		return t, err
+	}
+	if t.Threshold, err = strconv.Atoi(fields[2]); err != nil {
+		return t, fmt.Errorf("invalid threshold %q", fields[2])
+	}
+	t.Counted = t.From
+	if len(fields) == 6 {
+		if t.Counted, err = ParseSymbol(fields[3]); err != nil {
+			return t, err
+		}
+	}
+	if t.To, err = ParseSymbol(fields[len(fields)-1]); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// isBirthSurvival reports whether s looks like "B3/S23" notation
+func isBirthSurvival(s string) bool {
+	b, sv, ok := strings.Cut(strings.ToUpper(s), "/")
+	if !ok || !strings.HasPrefix(b, "B") || !strings.HasPrefix(sv, "S") {
+		return false
+	}
+	digits := b[1:] + sv[1:]
+	return strings.Trim(digits, "0123456789") == ""
+}
+
+// expandBirthSurvival converts "B3/S23" into transitions: a dead cell with a birth count
+// of live neighbors becomes alive, and a live cell without a survival count dies
+func expandBirthSurvival(s string, alive, dead rune, neighbors int) []Transition {
+	b, sv, _ := strings.Cut(strings.ToUpper(s), "/")
+
+	var transitions []Transition
+	for _, d := range b[1:] {
+		transitions = append(transitions, Transition{
+			From: dead, Counted: alive, Comparison: Equal, Threshold: int(d - '0'), To: alive,
+		})
+	}
+	for n := 0; n <= neighbors; n++ {
+		survives := n <= 9 && strings.ContainsRune(sv[1:], rune('0'+n))
+		if !survives {
+			transitions = append(transitions, Transition{
+				From: alive, Counted: alive, Comparison: Equal, Threshold: n, To: dead,
+			})
+		}
+	}
+	return transitions
+}
+
+// Run applies generations to a copy of grid until nothing changes, the generation
+// limit is reached or a previous state recurs. onGeneration may be nil.
+func (a *Automaton) Run(grid *Grid, onGeneration GenerationFunc) RunResult {
+	s := newAutomatonState(a, grid)
+
+	// Cycle detection keys each state by an incrementally maintained hash, listing the
+	// generations that had it. A match is confirmed by replaying the earlier generation and
+	// comparing the cells, so a hash collision cannot end the run early.
+	seen := map[uint64][]int{s.hash: {0}}
+	result := RunResult{Reason: StopStable}
+
+	for a.MaxGenerations == 0 || result.Generations < a.MaxGenerations {
+		changes := s.step()
+		if len(changes) == 0 {
+			break
+		}
+
+		result.Generations++
+		result.Changes += len(changes)
+		if onGeneration != nil {
+			reported := make([]Change, len(changes))
+			for k, c := range changes {
+				reported[k] = Change{Pos: s.position(c.index), From: c.from, To: c.to}
+			}
+			onGeneration(result.Generations, reported)
+		}
+
+		if prev, ok := a.recurrence(grid, s, seen[s.hash]); ok {
+			result.Reason = StopCycle
+			result.CycleStart = prev
+			result.CyclePeriod = result.Generations - prev
+			break
+		}
+		seen[s.hash] = append(seen[s.hash], result.Generations)
+
+		if a.MaxGenerations != 0 && result.Generations == a.MaxGenerations {
+			result.Reason = StopMaxGenerations
+		}
+	}
+
+	result.Grid = s.grid()
+	return result
+}
+
+// recurrence returns the generation among candidates, which share the current state's
+// hash, whose cells really match the current state
+func (a *Automaton) recurrence(grid *Grid, s *automatonState, candidates []int) (int, bool) {
+	if len(candidates) == 0 {
+		return 0, false
+	}
+	replay := newAutomatonState(a, grid)
+	generation := 0
+	for _, prev := range candidates { // in increasing order
+		for ; generation < prev; generation++ {
+			replay.step()
+		}
+		if slices.Equal(replay.cells, s.cells) {
+			return prev, true
+		}
+	}
+	return 0, false
+}
+
+// cellChange is a change to a flat cell index
+type cellChange struct {
+	index int
+	from  rune
+	to    rune
+}
+
+// automatonState holds the cells being evolved, in cellIndex scan order
+type automatonState struct {
+	cellIndex
+	automaton  *Automaton
+	cells      []rune
+	outside    rune // symbol read outside the grid when the edge policy does not map back in
+	fill       rune
+	hash       uint64
+	candidates []int  // cells to evaluate in the next synchronous generation
+	queued     []bool // whether a cell is already in candidates
+	scratch    []int
+}
+
+func newAutomatonState(a *Automaton, grid *Grid) *automatonState {
+	size := grid.Width * grid.Height
+	s := &automatonState{
+		cellIndex:  newCellIndex(grid),
+		automaton:  a,
+		cells:      make([]rune, size),
+		outside:    grid.outside(),
+		fill:       grid.Fill,
+		candidates: make([]int, size),
+		queued:     make([]bool, size),
+	}
+	for i := range s.cells {
+		s.cells[i] = grid.GetCell(s.position(i))
+		s.hash ^= cellHash(i, s.cells[i])
+		s.candidates[i] = i
+		s.queued[i] = true
+	}
+	return s
+}
+
+// next returns the value cell i takes under the first matching transition
+func (s *automatonState) next(i int) rune {
+	cell := s.cells[i]
+	pos := s.position(i)
+	for _, t := range s.automaton.Transitions {
+		if t.From != cell {
+			continue
+		}
+		if t.Comparison.Compare(s.countNeighbors(pos, t.Counted), t.Threshold) {
+			return t.To
+		}
+	}
+	return cell
+}
+
+// countNeighbors counts neighbors of pos holding symbol, reading out of bounds
+// positions through the grid's edge policy
+func (s *automatonState) countNeighbors(pos Position, symbol rune) int {
+	count := 0
+	for _, off := range s.automaton.Neighborhood {
+		cell := s.outside
+		if j, ok := s.index(Position{X: pos.X + off.DX, Y: pos.Y + off.DY}); ok {
+			cell = s.cells[j]
+		}
+		if cell == symbol {
+			count++
+		}
+	}
+	return count
+}
+
+// step runs one generation with the automaton's update mode
+func (s *automatonState) step() []cellChange {
+	if s.automaton.Update == Asynchronous {
+		return s.stepAsync()
+	}
+	return s.stepSync()
+}
+
+// stepSync evaluates only cells that changed or neighbor a change since their last
+// evaluation, since every other cell would reach the same result as before
+func (s *automatonState) stepSync() []cellChange {
+	slices.Sort(s.candidates)
+
+	var changes []cellChange
+	for _, i := range s.candidates {
+		s.queued[i] = false
+		if to := s.next(i); to != s.cells[i] {
+			changes = append(changes, cellChange{index: i, from: s.cells[i], to: to})
+		}
+	}
+
+	s.candidates = s.candidates[:0]
+	for _, c := range changes {
+		s.apply(c)
+	}
+	for _, c := range changes {
+		s.enqueue(c.index)
+		s.scratch = s.dependents(c.index, s.automaton.Neighborhood, s.scratch[:0])
+		for _, j := range s.scratch {
+			s.enqueue(j)
+		}
+	}
+
+	return changes
+}
+
+// stepAsync updates every cell in place in scan order
+func (s *automatonState) stepAsync() []cellChange {
+	var changes []cellChange
+	for i := range s.cells {
+		if to := s.next(i); to != s.cells[i] {
+			c := cellChange{index: i, from: s.cells[i], to: to}
+			s.apply(c)
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func (s *automatonState) apply(c cellChange) {
+	s.cells[c.index] = c.to
+	s.hash ^= cellHash(c.index, c.from) ^ cellHash(c.index, c.to)
+}
+
+func (s *automatonState) enqueue(i int) {
+	if !s.queued[i] {
+		s.queued[i] = true
+		s.candidates = append(s.candidates, i)
+	}
+}
+
+// grid converts the cells back into a Grid
+func (s *automatonState) grid() *Grid {
+	cells := make([][]rune, s.height)
+	for row := range cells {
+		cells[row] = make([]rune, s.width)
+		for col := range cells[row] {
+			i, _ := s.index(Position{X: col + 1, Y: s.height - row})
+			cells[row][col] = s.cells[i]
+		}
+	}
+	return &Grid{Cells: cells, Width: s.width, Height: s.height, Edge: s.edge, Fill: s.fill}
+}
+
+// cellHash is the Zobrist-style contribution of a cell value to the state hash;
+// the state hash is the XOR of every cell's contribution
+func cellHash(i int, r rune) uint64 {
+	// splitmix64 finalizer
+	z := uint64(i)*0x9e3779b97f4a7c15 + uint64(r)*0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"unicode/utf8"
+)
+
+// BitGrid is a bitset-backed grid for huge inputs. It stores one bit per cell, set
+// where the cell holds Symbol, so every other symbol reads as '.'. Positions use the
+// same 1-based bottom-left convention as Grid.
+type BitGrid struct {
+	Width  int
+	Height int
+	Symbol rune       // symbol stored as a set bit
+	Edge   EdgePolicy // what out of bounds positions read; EdgeFilled reads Symbol
+	stride int        // words per row
+	words  []uint64   // row-major with row 0 at the bottom (Y=1); bit x of a row is X=x+1
+}
+
+// NewBitGrid creates an empty BitGrid
+func NewBitGrid(width, height int, symbol rune) *BitGrid {
+	stride := (width + 63) / 64
+	return &BitGrid{
+		Width:  width,
+		Height: height,
+		Symbol: symbol,
+		stride: stride,
+		words:  make([]uint64, stride*height),
+	}
+}
+
+// NewBitGridFromGrid packs the cells of grid holding symbol into a BitGrid
+func NewBitGridFromGrid(grid *Grid, symbol rune) *BitGrid {
+	b := NewBitGrid(grid.Width, grid.Height, symbol)
+	b.Edge = grid.Edge
+	for y := 1; y <= grid.Height; y++ {
+		for x := 1; x <= grid.Width; x++ {
+			if grid.GetCell(Position{X: x, Y: y}) == symbol {
+				b.Set(Position{X: x, Y: y}, true)
+			}
+		}
+	}
+	return b
+}
+
+// NewBitGridFromFile reads a grid file straight into bits, without holding the
+// text in memory, so inputs with hundreds of millions of cells fit
+func NewBitGridFromFile(filepath string, symbol rune) (*BitGrid, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadBitGrid(file, symbol)
+}
+
+// ReadBitGrid reads a grid from r; the first line sets the width and shorter lines are padded with '.'
+func ReadBitGrid(r io.Reader, symbol rune) (*BitGrid, error) {
+	reader := bufio.NewReaderSize(r, 1<<20)
+	b := &BitGrid{Symbol: symbol}
+
+	var row []uint64
+	x := 0
+	for {
+		// ReadLine hands back long lines in pieces, so rows of any width stream through
+		chunk, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range chunk {
+			if !utf8.RuneStart(chunk[i]) {
+				continue
+			}
+			if b.Height > 0 && x >= b.Width {
+				return nil, fmt.Errorf("line %d is wider than the first line (%d)", b.Height+1, b.Width)
+			}
+			if x/64 >= len(row) {
+				row = append(row, 0)
+			}
+			if r, _ := utf8.DecodeRune(chunk[i:]); r == symbol {
+				row[x/64] |= 1 << (x % 64)
+			}
+			x++
+		}
+		if isPrefix {
+			continue
+		}
+
+		if b.Height == 0 {
+			b.Width = x
+			b.stride = (x + 63) / 64
+		}
+		row = append(row, make([]uint64, b.stride-len(row))...)
+		b.words = append(b.words, row...)
+		b.Height++
+		row, x = row[:0], 0
+	}
+
+	// Rows were read top first, but row 0 is the bottom
+	for top, bottom := 0, b.Height-1; top < bottom; top, bottom = top+1, bottom-1 {
+		t, u := b.row(top), b.row(bottom)
+		for i := range t {
+			t[i], u[i] = u[i], t[i]
+		}
+	}
+
+	return b, nil
+}
+
+// Clone returns an independent copy of the grid
+func (b *BitGrid) Clone() *BitGrid {
+	c := *b
+	c.words = append([]uint64(nil), b.words...)
+	return &c
+}
+
+// row returns the words of 0-based row y (0 is the bottom)
+func (b *BitGrid) row(y int) []uint64 {
+	return b.words[y*b.stride : (y+1)*b.stride]
+}
+
+// Set marks whether the in-bounds position holds the symbol
+func (b *BitGrid) Set(pos Position, on bool) {
+	x, y := pos.X-1, pos.Y-1
+	if x < 0 || x >= b.Width || y < 0 || y >= b.Height {
+		return
+	}
+	if on {
+		b.words[y*b.stride+x/64] |= 1 << (x % 64)
+	} else {
+		b.words[y*b.stride+x/64] &^= 1 << (x % 64)
+	}
+}
+
+// has reports whether pos holds the symbol, reading out of bounds positions through the edge policy
+func (b *BitGrid) has(pos Position) bool {
+	x, xOK := resolveAxis(pos.X-1, b.Width, b.Edge)
+	y, yOK := resolveAxis(pos.Y-1, b.Height, b.Edge)
+	if !xOK || !yOK {
+		return b.Edge == EdgeFilled
+	}
+	return b.words[y*b.stride+x/64]&(1<<(x%64)) != 0
+}
+
+// GetCell returns the rune at the given position (1-based coordinates): Symbol or '.'
+func (b *BitGrid) GetCell(pos Position) rune {
+	if b.has(pos) {
+		return b.Symbol
+	}
+	return '.'
+}
+
+// CountAdjacentAt counts how many of the 8 adjacent positions hold the symbol
+func (b *BitGrid) CountAdjacentAt(pos Position) int {
+	return b.CountNeighbors(pos, mooreDirections)
+}
+
+// CountNeighbors counts how many positions in the neighborhood of pos hold the symbol
+func (b *BitGrid) CountNeighbors(pos Position, neighborhood Neighborhood) int {
+	count := 0
+	for _, off := range neighborhood {
+		if b.has(Position{X: pos.X + off.DX, Y: pos.Y + off.DY}) {
+			count++
+		}
+	}
+	return count
+}
+
+// FindSelectedPositionsWith finds all positions holding the symbol whose neighbor
+// count satisfies the rule, 64 cells at a time. rule.Symbol must match the grid's Symbol.
+func (b *BitGrid) FindSelectedPositionsWith(rule Rule) []Position {
+	c := newBitCounter(b, rule)
+	var selected []Position
+	for y := 0; y < b.Height; y++ {
+		selected = appendRowPositions(selected, y, c.selectRow(y))
+	}
+	return selected
+}
+
+// RunCompletionBits produces the same rounds as RunCompletion on a BitGrid. Each round
+// only recounts rows within reach of a row that changed in the previous round.
+func RunCompletionBits(grid *BitGrid, rule Rule, onRound RoundFunc) (rounds, total int) {
+	b := grid.Clone()
+	c := newBitCounter(b, rule)
+
+	dirty := make([]bool, b.Height)
+	for y := range dirty {
+		dirty[y] = true
+	}
+	selection := make([]uint64, len(b.words))
+	var dys []int
+	for _, off := range rule.Neighborhood {
+		if !contains(dys, off.DY) {
+			dys = append(dys, off.DY)
+		}
+	}
+
+	for {
+		// Select from every dirty row before removing anything, keeping updates synchronous
+		var changedRows []int
+		var selected []Position
+		for y, isDirty := range dirty {
+			if !isDirty {
+				continue
+			}
+			dirty[y] = false
+			sel := c.selectRow(y)
+			if !anySet(sel) {
+				continue
+			}
+			copy(selection[y*b.stride:], sel)
+			changedRows = append(changedRows, y)
+			selected = appendRowPositions(selected, y, sel)
+		}
+		if len(selected) == 0 {
+			return rounds, total
+		}
+
+		rounds++
+		total += len(selected)
+		onRound(rounds, selected)
+
+		var sources []int
+		for _, y := range changedRows {
+			row, sel := b.row(y), selection[y*b.stride:(y+1)*b.stride]
+			for i := range row {
+				row[i] &^= sel[i]
+			}
+			// Mark every row whose neighborhood reads row y
+			for _, dy := range dys {
+				sources = axisSources(y, dy, b.Height, b.Edge, sources[:0])
+				for _, r := range sources {
+					dirty[r] = true
+				}
+			}
+		}
+	}
+}
+
+// bitCounter counts neighbors for a whole row at once with bit-sliced counters:
+// counters[k] holds bit k of the neighbor count of every cell in the row
+type bitCounter struct {
+	grid     *BitGrid
+	rule     Rule
+	counters [][]uint64
+	plane    []uint64
+	out      []uint64
+	fill     uint64 // bits read outside the grid under EdgeEmpty/EdgeFilled
+}
+
+func newBitCounter(b *BitGrid, rule Rule) *bitCounter {
+	c := &bitCounter{
+		grid:     b,
+		rule:     rule,
+		counters: make([][]uint64, bits.Len(uint(len(rule.Neighborhood)))),
+		plane:    make([]uint64, b.stride),
+		out:      make([]uint64, b.stride),
+	}
+	for k := range c.counters {
+		c.counters[k] = make([]uint64, b.stride)
+	}
+	if b.Edge == EdgeFilled {
+		c.fill = ^uint64(0)
+	}
+	return c
+}
+
+// selectRow returns the selection mask for 0-based row y; it is reused by the next call
+func (c *bitCounter) selectRow(y int) []uint64 {
+	b := c.grid
+	for _, counter := range c.counters {
+		clear(counter)
+	}
+
+	for _, off := range c.rule.Neighborhood {
+		c.shiftedRow(y+off.DY, off.DX)
+		// Ripple-carry add the plane into the counters
+		for i, carry := range c.plane {
+			for k := 0; k < len(c.counters) && carry != 0; k++ {
+				next := c.counters[k][i] & carry
+				c.counters[k][i] ^= carry
+				carry = next
+			}
+		}
+	}
+
+	alive := b.row(y)
+	for i := range c.out {
+		less, equal := c.compareThreshold(i)
+		var match uint64
+		switch c.rule.Comparison {
+		case LessThan:
+			match = less
+		case LessOrEqual:
+			match = less | equal
+		case Equal:
+			match = equal
+		case NotEqual:
+			match = ^equal
+		case GreaterOrEqual:
+			match = ^less
+		case GreaterThan:
+			match = ^(less | equal)
+		}
+		c.out[i] = match & alive[i]
+	}
+	return c.out
+}
+
+// compareThreshold returns masks of the cells in word i whose count is less than
+// and equal to the rule's threshold
+func (c *bitCounter) compareThreshold(i int) (less, equal uint64) {
+	t := c.rule.Threshold
+	switch {
+	case t < 0:
+		return 0, 0
+	case t >= 1<<len(c.counters):
+		return ^uint64(0), 0
+	}
+
+	// Compare from the most significant bit down, as with ordinary binary numbers
+	equal = ^uint64(0)
+	for k := len(c.counters) - 1; k >= 0; k-- {
+		bit := c.counters[k][i]
+		if t&(1<<k) != 0 {
+			less |= equal &^ bit
+			equal &= bit
+		} else {
+			equal &^= bit
+		}
+	}
+	return less, equal
+}
+
+// shiftedRow fills c.plane so bit x is the cell at (x+dx, y) of the grid, reading out of
+// bounds cells through the edge policy. y is 0-based from the bottom.
+func (c *bitCounter) shiftedRow(y, dx int) {
+	b := c.grid
+	plane := c.plane
+
+	sy, ok := resolveAxis(y, b.Height, b.Edge)
+	if !ok {
+		for i := range plane {
+			plane[i] = c.fill
+		}
+		b.maskRow(plane)
+		return
+	}
+	src := b.row(sy)
+
+	shiftWords(plane, src, dx)
+
+	// Bits shifted in from beyond the row's ends follow the edge policy
+	if dx != 0 && b.Edge != EdgeEmpty {
+		lo, hi := b.Width-dx, b.Width
+		if dx < 0 {
+			lo, hi = 0, min(-dx, b.Width)
+		}
+		for x := max(lo, 0); x < hi; x++ {
+			var on bool
+			if sx, ok := resolveAxis(x+dx, b.Width, b.Edge); ok {
+				on = src[sx/64]&(1<<(sx%64)) != 0
+			} else {
+				on = b.Edge == EdgeFilled
+			}
+			if on {
+				plane[x/64] |= 1 << (x % 64)
+			} else {
+				plane[x/64] &^= 1 << (x % 64)
+			}
+		}
+	}
+	b.maskRow(plane)
+}
+
+// maskRow clears the bits beyond the grid's width in the last word of a row
+func (b *BitGrid) maskRow(row []uint64) {
+	if rem := b.Width % 64; rem != 0 && len(row) > 0 {
+		row[len(row)-1] &= 1<<rem - 1
+	}
+}
+
+// shiftWords sets bit x of dst to bit x+dx of src, with zeros beyond src
+func shiftWords(dst, src []uint64, dx int) {
+	n := len(src)
+	word := func(i int) uint64 {
+		if i < 0 || i >= n {
+			return 0
+		}
+		return src[i]
+	}
+
+	ws, bs := floorDiv(dx, 64), uint(mod(dx, 64))
+	for i := range dst {
+		if bs == 0 {
+			dst[i] = word(i + ws)
+		} else {
+			dst[i] = word(i+ws)>>bs | word(i+ws+1)<<(64-bs)
+		}
+	}
+}
+
+// appendRowPositions appends the positions of the set bits of 0-based row y in scan order
+func appendRowPositions(positions []Position, y int, row []uint64) []Position {
+	for i, word := range row {
+		for word != 0 {
+			bit := bits.TrailingZeros64(word)
+			positions = append(positions, Position{X: i*64 + bit + 1, Y: y + 1})
+			word &= word - 1
+		}
+	}
+	return positions
+}
+
+func anySet(row []uint64) bool {
+	for _, w := range row {
+		if w != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []int, v int) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Depth values for cells that are not removed
+const (
+	DepthNever = -1 // the cell held the symbol and was never removed (the stable core)
+	DepthEmpty = 0  // the cell never held the symbol
+)
+
+// DepthMap records, for every cell holding the symbol at the start of completion mode,
+// the round in which it was removed
+type DepthMap struct {
+	cellIndex
+	Rounds int     // number of rounds recorded
+	depth  []int32 // per cell in scan order: round removed, DepthNever or DepthEmpty
+}
+
+// NewDepthMap creates a DepthMap with every symbol cell marked as never removed
+func NewDepthMap(grid *Grid, symbol rune) *DepthMap {
+	d := &DepthMap{
+		cellIndex: newCellIndex(grid),
+		depth:     make([]int32, grid.Width*grid.Height),
+	}
+	for i := range d.depth {
+		if grid.GetCell(d.position(i)) == symbol {
+			d.depth[i] = DepthNever
+		}
+	}
+	return d
+}
+
+// Record marks the positions selected in a round as removed in that round; it can be
+// passed directly as a RoundFunc or called from one
+func (d *DepthMap) Record(round int, selected []Position) {
+	for _, pos := range selected {
+		if i, ok := d.index(pos); ok {
+			d.depth[i] = int32(round)
+		}
+	}
+	d.Rounds = max(d.Rounds, round)
+}
+
+// Depth returns the round pos was removed in, DepthNever or DepthEmpty
+func (d *DepthMap) Depth(pos Position) int {
+	if pos.X < 1 || pos.X > d.width || pos.Y < 1 || pos.Y > d.height {
+		return DepthEmpty
+	}
+	return int(d.depth[(pos.Y-1)*d.width+pos.X-1])
+}
+
+// Histogram returns how many cells were removed in each round, indexed by round
+// (index 0 is unused), and how many were never removed
+func (d *DepthMap) Histogram() (perRound []int, never int) {
+	perRound = make([]int, d.Rounds+1)
+	for _, depth := range d.depth {
+		switch {
+		case depth == DepthNever:
+			never++
+		case depth > 0:
+			perRound[depth]++
+		}
+	}
+	return perRound, never
+}
+
+// Core returns the positions that were never removed, in scan order
+func (d *DepthMap) Core() []Position {
+	var core []Position
+	for i, depth := range d.depth {
+		if depth == DepthNever {
+			core = append(core, d.position(i))
+		}
+	}
+	return core
+}
+
+// WriteGrid writes the depth map in the layout of the input, top row first. Each cell is
+// its removal round, '*' if never removed or '.' if it never held the symbol, padded to
+// a common width and separated by spaces when rounds need more than one digit.
+func (d *DepthMap) WriteGrid(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width := len(strconv.Itoa(d.Rounds))
+	sep := ""
+	if width > 1 {
+		sep = " "
+	}
+
+	for y := d.height; y >= 1; y-- {
+		for x := 1; x <= d.width; x++ {
+			if x > 1 {
+				bw.WriteString(sep)
+			}
+			var cell string
+			switch depth := d.Depth(Position{X: x, Y: y}); depth {
+			case DepthNever:
+				cell = "*"
+			case DepthEmpty:
+				cell = "."
+			default:
+				cell = strconv.Itoa(depth)
+			}
+			bw.WriteString(strings.Repeat(" ", width-len(cell)))
+			bw.WriteString(cell)
+		}
+		bw.WriteString("\n")
+	}
+
+	return bw.Flush()
+}
+
+// WriteCSV writes one "x,y,round" record per cell that held the symbol, in scan order,
+// with "never" as the round for the stable core
+func (d *DepthMap) WriteCSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("x,y,round\n")
+	for i, depth := range d.depth {
+		if depth == DepthEmpty {
+			continue
+		}
+		pos := d.position(i)
+		round := "never"
+		if depth != DepthNever {
+			round = strconv.Itoa(int(depth))
+		}
+		fmt.Fprintf(bw, "%d,%d,%s\n", pos.X, pos.Y, round)
+	}
+	return bw.Flush()
+}
+
+// WriteStats writes the per-round histogram and the stable core
+func (d *DepthMap) WriteStats(w io.Writer) {
+	perRound, never := d.Histogram()
+
+	largest := never
+	for _, n := range perRound {
+		largest = max(largest, n)
+	}
+	bar := func(n int) string {
+		if largest == 0 {
+			return ""
+		}
+		return strings.Repeat("#", (n*50+largest-1)/largest)
+	}
+
+	fmt.Fprintln(w, "=== Layer Statistics ===")
+	fmt.Fprintln(w, "Removed per round:")
+	labelWidth := len(strconv.Itoa(d.Rounds))
+	for r := 1; r <= d.Rounds; r++ {
+		fmt.Fprintf(w, "Round %*d: %6d %s\n", labelWidth, r, perRound[r], bar(perRound[r]))
+	}
+	fmt.Fprintf(w, "Never removed: %d %s\n", never, bar(never))
+
+	fmt.Fprintln(w, "Stable core:")
+	for _, pos := range d.Core() {
+		fmt.Fprintf(w, "[%d,%d]\n", pos.X, pos.Y)
+	}
+	fmt.Fprintf(w, "Stable core size: %d\n", never)
+}
+
+// writeDepthFile writes a depth map to path with the given writer method
+func writeDepthFile(path string, write func(*DepthMap, io.Writer) error, d *DepthMap) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(d, f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import "fmt"
+
+// EdgePolicy decides what a Grid reads for positions outside its bounds
+type EdgePolicy int
+
+const (
+	// EdgeEmpty reads '.' outside the grid (the original behavior)
+	EdgeEmpty EdgePolicy = iota
+	// EdgeWrap joins opposite edges, making the grid a torus
+	EdgeWrap
+	// EdgeReflect mirrors the grid at its edges, so one step outside reads the edge cell itself
+	EdgeReflect
+	// EdgeFilled reads the grid's Fill symbol ('@' by default) outside the grid
+	EdgeFilled
+)
+
+var edgePolicyNames = map[EdgePolicy]string{
+	EdgeEmpty:   "empty",
+	EdgeWrap:    "wrap",
+	EdgeReflect: "reflect",
+	EdgeFilled:  "filled",
+}
+
+// ParseEdgePolicy converts "empty", "wrap", "reflect" or "filled" into an EdgePolicy
+func ParseEdgePolicy(s string) (EdgePolicy, error) {
+	for policy, name := range edgePolicyNames {
+		if s == name {
+			return policy, nil
+		}
+	}
+	switch s {
+	case "torus":
+		return EdgeWrap, nil
+	case "mirror":
+		return EdgeReflect, nil
+	}
+	return EdgeEmpty, fmt.Errorf("unknown edge policy %q: must be 'empty', 'wrap', 'reflect' or 'filled'", s)
+}
+
+// String returns the command line name of the edge policy
+func (e EdgePolicy) String() string {
+	if name, ok := edgePolicyNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("EdgePolicy(%d)", int(e))
+}
+
+// resolveAxis maps a 0-based coordinate on an axis of length n to the in-bounds
+// coordinate it reads from, reporting false when it reads the outside value instead
+func resolveAxis(v, n int, edge EdgePolicy) (int, bool) {
+	if v >= 0 && v < n {
+		return v, true
+	}
+	switch edge {
+	case EdgeWrap:
+		return mod(v, n), true
+	case EdgeReflect:
+		// Mirroring at both edges repeats with period 2n: 0..n-1 then n-1..0
+		v = mod(v, 2*n)
+		if v >= n {
+			v = 2*n - 1 - v
+		}
+		return v, true
+	}
+	return 0, false
+}
+
+// axisSources appends every in-bounds coordinate p on an axis of length n whose
+// neighbor at displacement d resolves to c, i.e. resolveAxis(p+d) == c
+func axisSources(c, d, n int, edge EdgePolicy, buf []int) []int {
+	switch edge {
+	case EdgeWrap:
+		return append(buf, mod(c-d, n))
+	case EdgeReflect:
+		// c is read from c and 2n-1-c in every period of 2n; collect those that
+		// lie in the window [d, n+d) reachable from in-bounds positions
+		for _, base := range []int{c, 2*n - 1 - c} {
+			first := base + 2*n*floorDiv(d-base+2*n-1, 2*n)
+			for v := first; v < n+d; v += 2 * n {
+				buf = append(buf, v-d)
+			}
+		}
+		return buf
+	}
+	if p := c - d; p >= 0 && p < n {
+		buf = append(buf, p)
+	}
+	return buf
+}
+
+// mod returns v modulo n in the range [0, n)
+func mod(v, n int) int {
+	v %= n
+	if v < 0 {
+		v += n
+	}
+	return v
+}
+
+// floorDiv divides rounding toward negative infinity
+func floorDiv(a, b int) int {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+	return q
+}
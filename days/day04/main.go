@@ -1,86 +1,340 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
 	"os"
 )
 
 func main() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: day4 <filepath> <mode>")
-		fmt.Println("  mode: 'initial' for single pass, 'completion' for iterative passes")
+		fmt.Println("Usage: day4 <filepath> <mode> [options]")
+		fmt.Println("  mode: 'initial' for single pass, 'completion' for iterative passes, 'rules' to run a rule file")
+		fmt.Println("  options:")
+		fmt.Println("    -symbol <c>           symbol to select (default '@')")
+		fmt.Println("    -compare <op>         comparison against threshold: <, <=, ==, !=, >=, > (default '<')")
+		fmt.Println("    -threshold <n>        neighbor count threshold (default 4)")
+		fmt.Println("    -neighborhood <spec>  'moore[:r]', 'vonneumann[:r]' or 'custom:dx,dy;dx,dy;...' (default 'moore')")
+		fmt.Println("    -edge <policy>        out of bounds cells: 'empty', 'wrap', 'reflect' or 'filled' with the symbol (default 'empty')")
+		fmt.Println("    -engine <name>        'worklist' (incremental), 'rescan' or 'bitset' (bit-packed grid for huge inputs) (default 'worklist')")
+		fmt.Println("    -gif <file>           write an animated GIF of completion rounds")
+		fmt.Println("    -svg <file>           write an SVG with one layer per completion round")
+		fmt.Println("    -depth <file>         write the round each cell was removed in as a grid")
+		fmt.Println("    -depth-csv <file>     write the round each cell was removed in as CSV")
+		fmt.Println("    -stats                print per-round removal histogram and the stable core")
+		fmt.Println("    -rules <file|preset>  rule file, or preset 'initial'/'completion', for 'rules' mode")
+		fmt.Println("    -update <mode>        override the rule file update mode: 'sync' or 'async'")
+		fmt.Println("    -max-generations <n>  override the rule file generation limit (0 for none)")
 		os.Exit(1)
 	}
 
 	filepath := os.Args[1]
 	mode := os.Args[2]
 
-	if mode != "initial" && mode != "completion" {
-		fmt.Println("Error: mode must be 'initial' or 'completion'")
+	if mode != "initial" && mode != "completion" && mode != "rules" {
+		fmt.Println("Error: mode must be 'initial', 'completion' or 'rules'")
 		os.Exit(1)
 	}
 
+	opts, err := parseOptions(os.Args[3:])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if opts.engine == EngineBitset {
+		if mode == "rules" {
+			fmt.Println("Error: 'rules' mode does not support the bitset engine")
+			os.Exit(1)
+		}
+		runBitGrid(filepath, mode, opts)
+		return
+	}
+
+	var automaton *Automaton
+	if mode == "rules" {
+		if automaton, err = opts.automaton(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Load the grid from file
 	grid, err := NewGridFromFile(filepath)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		os.Exit(1)
 	}
+	grid.Edge = opts.edge
+	grid.Fill = opts.rule.Symbol
 
-	if mode == "initial" {
-		runInitialPass(grid)
+	switch mode {
+	case "initial":
+		runInitialPass(grid, opts.rule)
+	case "completion":
+		runCompletionMode(grid, opts, func(onRound RoundFunc) (int, int, error) {
+			return RunCompletionWith(opts.engine, grid, opts.rule, onRound)
+		})
+	case "rules":
+		runRulesMode(grid, automaton)
+	}
+}
+
+// options holds the settings parsed from the optional command line flags
+type options struct {
+	rule           Rule
+	edge           EdgePolicy
+	engine         string
+	gifFile        string
+	svgFile        string
+	depthFile      string
+	depthCSVFile   string
+	stats          bool
+	rules          string
+	update         string
+	maxGenerations int
+}
+
+// parseOptions builds the selection Rule and engine choice from the optional command line flags
+func parseOptions(args []string) (options, error) {
+	opts := options{rule: DefaultRule(), engine: EngineWorklist, maxGenerations: -1}
+	rule := &opts.rule
+
+	fs := flag.NewFlagSet("day4", flag.ContinueOnError)
+	symbol := fs.String("symbol", string(rule.Symbol), "symbol to select")
+	compare := fs.String("compare", rule.Comparison.String(), "comparison against threshold")
+	threshold := fs.Int("threshold", rule.Threshold, "neighbor count threshold")
+	neighborhood := fs.String("neighborhood", "moore", "neighborhood shape")
+	edge := fs.String("edge", EdgeEmpty.String(), "edge policy")
+	fs.StringVar(&opts.engine, "engine", opts.engine, "completion engine")
+	fs.StringVar(&opts.gifFile, "gif", "", "animated GIF output file")
+	fs.StringVar(&opts.svgFile, "svg", "", "layered SVG output file")
+	fs.StringVar(&opts.depthFile, "depth", "", "removal depth grid output file")
+	fs.StringVar(&opts.depthCSVFile, "depth-csv", "", "removal depth CSV output file")
+	fs.BoolVar(&opts.stats, "stats", false, "print layer statistics")
+	fs.StringVar(&opts.rules, "rules", "", "rule file or preset name")
+	fs.StringVar(&opts.update, "update", "", "update mode override")
+	fs.IntVar(&opts.maxGenerations, "max-generations", opts.maxGenerations, "generation limit override")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	switch opts.engine {
+	case EngineWorklist, EngineRescan:
+	case EngineBitset:
+		if opts.gifFile != "" || opts.svgFile != "" || opts.depthFile != "" || opts.depthCSVFile != "" || opts.stats {
+			return opts, fmt.Errorf("the bitset engine does not support -gif, -svg, -depth, -depth-csv or -stats")
+		}
+	default:
+		return opts, fmt.Errorf("engine must be '%s', '%s' or '%s'", EngineWorklist, EngineRescan, EngineBitset)
+	}
+
+	var err error
+	if rule.Symbol, err = ParseSymbol(*symbol); err != nil {
+		return opts, err
+	}
+	if rule.Symbol == '.' {
+		return opts, fmt.Errorf("symbol '.' is the empty cell and cannot be selected")
+	}
+	if rule.Comparison, err = ParseComparison(*compare); err != nil {
+		return opts, err
+	}
+	if rule.Neighborhood, err = ParseNeighborhood(*neighborhood); err != nil {
+		return opts, err
+	}
+	if opts.edge, err = ParseEdgePolicy(*edge); err != nil {
+		return opts, err
+	}
+	rule.Threshold = *threshold
+
+	return opts, nil
+}
+
+// automaton resolves -rules to a preset or rule file and applies the command line overrides
+func (o options) automaton() (*Automaton, error) {
+	if o.rules == "" {
+		return nil, fmt.Errorf("'rules' mode requires -rules <file|preset>")
+	}
+
+	var a *Automaton
+	if preset, ok := Presets[o.rules]; ok {
+		a = preset(o.rule)
 	} else {
-		runCompletionMode(grid)
+		var err error
+		if a, err = LoadAutomaton(o.rules); err != nil {
+			return nil, fmt.Errorf("loading rules %s: %v", o.rules, err)
+		}
 	}
+
+	if o.update != "" {
+		update, err := ParseUpdateMode(o.update)
+		if err != nil {
+			return nil, err
+		}
+		a.Update = update
+	}
+	if o.maxGenerations >= 0 {
+		a.MaxGenerations = o.maxGenerations
+	}
+
+	return a, nil
 }
 
-func runInitialPass(grid *Grid) {
-	// Find all selected positions
-	selected := grid.FindSelectedPositions()
+func runInitialPass(grid *Grid, rule Rule) {
+	// Find all selected positions: the changes made by one generation of the initial preset
+	var selected []Position
+	Presets["initial"](rule).Run(grid, func(generation int, changes []Change) {
+		for _, c := range changes {
+			selected = append(selected, c.Pos)
+		}
+	})
+
+	printInitialPass(selected)
+}
+
+func printInitialPass(selected []Position) {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
 
 	// Print each selected position
-	fmt.Println("Selected positions:")
+	fmt.Fprintln(out, "Selected positions:")
 	for _, pos := range selected {
-		fmt.Printf("[%d,%d]\n", pos.X, pos.Y)
+		fmt.Fprintf(out, "[%d,%d]\n", pos.X, pos.Y)
 	}
 
 	// Print the total count
-	fmt.Printf("\nTotal count: %d\n", len(selected))
+	fmt.Fprintf(out, "\nTotal count: %d\n", len(selected))
 }
 
-func runCompletionMode(grid *Grid) {
+// runBitGrid runs initial or completion mode on a bit-packed grid
+func runBitGrid(filepath, mode string, opts options) {
+	grid, err := NewBitGridFromFile(filepath, opts.rule.Symbol)
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	grid.Edge = opts.edge
+
+	if mode == "initial" {
+		printInitialPass(grid.FindSelectedPositionsWith(opts.rule))
+		return
+	}
+	runCompletionMode(nil, opts, func(onRound RoundFunc) (int, int, error) {
+		rounds, total := RunCompletionBits(grid, opts.rule, onRound)
+		return rounds, total, nil
+	})
+}
+
+// runCompletionMode prints the rounds produced by run. grid is only needed for the
+// visualization and depth options.
+func runCompletionMode(grid *Grid, opts options, run func(onRound RoundFunc) (rounds, total int, err error)) {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
 	allPositions := []Position{}
 	runningTotal := 0
-	round := 1
+	var rounds [][]Position
+	var depths *DepthMap
+	if opts.depthFile != "" || opts.depthCSVFile != "" || opts.stats {
+		depths = NewDepthMap(grid, opts.rule.Symbol)
+	}
 
-	for {
-		selected := grid.FindSelectedPositions()
-		if len(selected) == 0 {
-			break
+	numRounds, total, err := run(func(round int, selected []Position) {
+		if opts.gifFile != "" || opts.svgFile != "" {
+			rounds = append(rounds, selected)
+		}
+		if depths != nil {
+			depths.Record(round, selected)
 		}
 
 		// Print round information
-		fmt.Printf("Round %d:\n", round)
-		fmt.Println("Selected positions:")
+		fmt.Fprintf(out, "Round %d:\n", round)
+		fmt.Fprintln(out, "Selected positions:")
 		for _, pos := range selected {
-			fmt.Printf("[%d,%d]\n", pos.X, pos.Y)
+			fmt.Fprintf(out, "[%d,%d]\n", pos.X, pos.Y)
 			allPositions = append(allPositions, pos)
 		}
 		runningTotal += len(selected)
-		fmt.Printf("Total from round: %d\n", len(selected))
-		fmt.Printf("Running total: %d\n\n", runningTotal)
-
-		// Create new grid with selected positions replaced by '.'
-		grid = grid.ReplacePositions(selected)
-		round++
+		fmt.Fprintf(out, "Total from round: %d\n", len(selected))
+		fmt.Fprintf(out, "Running total: %d\n\n", runningTotal)
+	})
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return
 	}
 
 	// Print final summary
-	fmt.Println("=== Final Summary ===")
-	fmt.Println("All selected positions:")
+	fmt.Fprintln(out, "=== Final Summary ===")
+	fmt.Fprintln(out, "All selected positions:")
 	for _, pos := range allPositions {
-		fmt.Printf("[%d,%d]\n", pos.X, pos.Y)
+		fmt.Fprintf(out, "[%d,%d]\n", pos.X, pos.Y)
+	}
+	fmt.Fprintf(out, "\nNumber of rounds: %d\n", numRounds)
+	fmt.Fprintf(out, "Final total: %d\n", total)
+
+	if opts.stats {
+		fmt.Fprintln(out)
+		depths.WriteStats(out)
+	}
+	if opts.depthFile != "" {
+		if err := writeDepthFile(opts.depthFile, (*DepthMap).WriteGrid, depths); err != nil {
+			fmt.Fprintf(out, "Error writing depth map: %v\n", err)
+		} else {
+			fmt.Fprintf(out, "Depth map saved to: %s\n", opts.depthFile)
+		}
+	}
+	if opts.depthCSVFile != "" {
+		if err := writeDepthFile(opts.depthCSVFile, (*DepthMap).WriteCSV, depths); err != nil {
+			fmt.Fprintf(out, "Error writing depth CSV: %v\n", err)
+		} else {
+			fmt.Fprintf(out, "Depth CSV saved to: %s\n", opts.depthCSVFile)
+		}
+	}
+	if opts.gifFile != "" {
+		if err := writeRemovalFile(opts.gifFile, WriteRemovalGIF, grid, rounds, opts.rule.Symbol); err != nil {
+			fmt.Fprintf(out, "Error writing GIF: %v\n", err)
+		} else {
+			fmt.Fprintf(out, "Animation saved to: %s\n", opts.gifFile)
+		}
+	}
+	if opts.svgFile != "" {
+		if err := writeRemovalFile(opts.svgFile, WriteRemovalSVG, grid, rounds, opts.rule.Symbol); err != nil {
+			fmt.Fprintf(out, "Error writing SVG: %v\n", err)
+		} else {
+			fmt.Fprintf(out, "Visualization saved to: %s\n", opts.svgFile)
+		}
+	}
+}
+
+func runRulesMode(grid *Grid, automaton *Automaton) {
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	runningTotal := 0
+	result := automaton.Run(grid, func(generation int, changes []Change) {
+		fmt.Fprintf(out, "Generation %d:\n", generation)
+		for _, c := range changes {
+			fmt.Fprintf(out, "[%d,%d] %c -> %c\n", c.Pos.X, c.Pos.Y, c.From, c.To)
+		}
+		runningTotal += len(changes)
+		fmt.Fprintf(out, "Changes in generation: %d\n", len(changes))
+		fmt.Fprintf(out, "Running total: %d\n\n", runningTotal)
+	})
+
+	fmt.Fprintln(out, "=== Final Summary ===")
+	fmt.Fprintf(out, "Number of generations: %d\n", result.Generations)
+	fmt.Fprintf(out, "Total changes: %d\n", result.Changes)
+	if result.Reason == StopCycle {
+		fmt.Fprintf(out, "Stopped: %s (generation %d repeats generation %d, period %d)\n",
+			result.Reason, result.Generations, result.CycleStart, result.CyclePeriod)
+	} else {
+		fmt.Fprintf(out, "Stopped: %s\n", result.Reason)
+	}
+	fmt.Fprintln(out, "Final grid:")
+	for _, row := range result.Grid.Cells {
+		fmt.Fprintln(out, string(row))
 	}
-	fmt.Printf("\nNumber of rounds: %d\n", round-1)
-	fmt.Printf("Final total: %d\n", runningTotal)
 }
@@ -1,6 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -186,3 +191,526 @@ func TestCompletionMode(t *testing.T) {
 		t.Errorf("Expected 9 rounds, got %d", rounds)
 	}
 }
+
+func TestParseNeighborhood(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected int
+		wantErr  bool
+	}{
+		{"moore", 8, false},
+		{"moore:2", 24, false},
+		{"vonneumann", 4, false},
+		{"von-neumann:2", 12, false},
+		{"custom:-1,0;1,0;0,1", 3, false},
+		{"custom:0,0", 0, true},
+		{"custom:1,0;1,0", 0, true},
+		{"moore:0", 0, true},
+		{"hex", 0, true},
+	}
+
+	for _, tt := range tests {
+		n, err := ParseNeighborhood(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseNeighborhood(%q) expected error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseNeighborhood(%q) unexpected error: %v", tt.spec, err)
+			continue
+		}
+		if len(n) != tt.expected {
+			t.Errorf("ParseNeighborhood(%q) has %d offsets, expected %d", tt.spec, len(n), tt.expected)
+		}
+	}
+}
+
+func TestFindSelectedPositionsWithRule(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		rule     Rule
+		expected int
+	}{
+		{"default", DefaultRule(), 13},
+		{"von neumann < 2", Rule{Symbol: '@', Comparison: LessThan, Threshold: 2, Neighborhood: VonNeumannNeighborhood(1)}, 11},
+		{"moore radius 2 <= 10", Rule{Symbol: '@', Comparison: LessOrEqual, Threshold: 10, Neighborhood: MooreNeighborhood(2)}, 20},
+		{"horizontal isolated", Rule{Symbol: '@', Comparison: Equal, Threshold: 0, Neighborhood: Neighborhood{{DX: -1}, {DX: 1}}}, 13},
+	}
+
+	for _, tt := range tests {
+		selected := grid.FindSelectedPositionsWith(tt.rule)
+		if len(selected) != tt.expected {
+			t.Errorf("%s: expected %d selected positions, got %d", tt.name, tt.expected, len(selected))
+		}
+	}
+}
+
+func TestCompletionWorklistMatchesRescan(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	rules := []Rule{
+		DefaultRule(),
+		{Symbol: '@', Comparison: LessThan, Threshold: 2, Neighborhood: VonNeumannNeighborhood(1)},
+		{Symbol: '@', Comparison: LessOrEqual, Threshold: 10, Neighborhood: MooreNeighborhood(2)},
+		{Symbol: '@', Comparison: GreaterThan, Threshold: 0, Neighborhood: Neighborhood{{DX: -1}, {DX: 2, DY: 1}}},
+	}
+
+	for _, rule := range rules {
+		var expected, actual [][]Position
+		expectedRounds, expectedTotal := RunCompletion(grid, rule, func(round int, selected []Position) {
+			expected = append(expected, selected)
+		})
+		rounds, total := RunCompletionWorklist(grid, rule, func(round int, selected []Position) {
+			actual = append(actual, selected)
+		})
+
+		if rounds != expectedRounds || total != expectedTotal {
+			t.Errorf("Rule %v: worklist gave %d rounds/%d total, rescan gave %d rounds/%d total",
+				rule, rounds, total, expectedRounds, expectedTotal)
+			continue
+		}
+		for i := range expected {
+			if !slices.Equal(actual[i], expected[i]) {
+				t.Errorf("Rule %v: round %d differs: got %v, expected %v", rule, i+1, actual[i], expected[i])
+			}
+		}
+	}
+
+	// The default rule still matches the known completion result
+	rounds, total := RunCompletionWorklist(grid, DefaultRule(), func(int, []Position) {})
+	if rounds != 9 || total != 43 {
+		t.Errorf("Expected 9 rounds and 43 total, got %d rounds and %d total", rounds, total)
+	}
+}
+
+func TestPresetsMatchModes(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	var expected [][]Position
+	RunCompletion(grid, DefaultRule(), func(round int, selected []Position) {
+		expected = append(expected, selected)
+	})
+
+	var actual [][]Position
+	result := Presets["completion"](DefaultRule()).Run(grid, func(generation int, changes []Change) {
+		var positions []Position
+		for _, c := range changes {
+			positions = append(positions, c.Pos)
+		}
+		actual = append(actual, positions)
+	})
+
+	if result.Generations != 9 || result.Changes != 43 || result.Reason != StopStable {
+		t.Errorf("Expected completion preset to stop stable after 9 generations and 43 changes, got %d, %d, %v",
+			result.Generations, result.Changes, result.Reason)
+	}
+	for i := range expected {
+		if i >= len(actual) || !slices.Equal(actual[i], expected[i]) {
+			t.Errorf("Generation %d differs from completion round", i+1)
+		}
+	}
+
+	result = Presets["initial"](DefaultRule()).Run(grid, nil)
+	if result.Generations != 1 || result.Changes != 13 || result.Reason != StopMaxGenerations {
+		t.Errorf("Expected initial preset to make 13 changes in 1 generation, got %d in %d (%v)",
+			result.Changes, result.Generations, result.Reason)
+	}
+
+	// Asynchronous updates let removals cascade within a generation
+	async := Presets["completion"](DefaultRule())
+	async.Update = Asynchronous
+	result = async.Run(grid, nil)
+	if result.Generations != 5 || result.Changes != 43 {
+		t.Errorf("Expected async completion to make 43 changes in 5 generations, got %d in %d",
+			result.Changes, result.Generations)
+	}
+
+	// The input grid is left untouched
+	if len(grid.FindSelectedPositions()) != 13 {
+		t.Errorf("Run should not modify the input grid")
+	}
+}
+
+func TestParseAutomaton(t *testing.T) {
+	a, err := ParseAutomaton(strings.NewReader(`
+# comment
+neighborhood vonneumann
+update async
+max-generations 7
+. >= 2 @ -> #
+@ < 4 -> .
+`))
+	if err != nil {
+		t.Fatalf("ParseAutomaton failed: %v", err)
+	}
+	if len(a.Neighborhood) != 4 || a.Update != Asynchronous || a.MaxGenerations != 7 {
+		t.Errorf("Directives not applied: %d neighbors, %v, max %d", len(a.Neighborhood), a.Update, a.MaxGenerations)
+	}
+	expected := []Transition{
+		{From: '.', Counted: '@', Comparison: GreaterOrEqual, Threshold: 2, To: '#'},
+		{From: '@', Counted: '@', Comparison: LessThan, Threshold: 4, To: '.'},
+	}
+	if !slices.Equal(a.Transitions, expected) {
+		t.Errorf("Transitions = %v, expected %v", a.Transitions, expected)
+	}
+
+	// B3/S23 on a Moore neighborhood: 1 birth transition, death for 0,1,4,5,6,7,8
+	life, err := LoadAutomaton("life.rules")
+	if err != nil {
+		t.Fatalf("LoadAutomaton failed: %v", err)
+	}
+	if len(life.Transitions) != 8 {
+		t.Errorf("Expected 8 transitions from B3/S23, got %d", len(life.Transitions))
+	}
+
+	for _, bad := range []string{"", "@ < 4 .", "@ ~ 4 -> .", "update sideways\n@ < 4 -> .", "max-generations -1\n@ < 4 -> ."} {
+		if _, err := ParseAutomaton(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseAutomaton(%q) expected error", bad)
+		}
+	}
+}
+
+func TestAutomatonCycleDetection(t *testing.T) {
+	// A blinker oscillates between horizontal and vertical with period 2
+	grid := &Grid{
+		Cells: [][]rune{
+			[]rune("....."),
+			[]rune("....."),
+			[]rune(".@@@."),
+			[]rune("....."),
+			[]rune("....."),
+		},
+		Width:  5,
+		Height: 5,
+	}
+
+	life, err := LoadAutomaton("life.rules")
+	if err != nil {
+		t.Fatalf("LoadAutomaton failed: %v", err)
+	}
+
+	result := life.Run(grid, nil)
+	if result.Reason != StopCycle || result.CycleStart != 0 || result.CyclePeriod != 2 || result.Generations != 2 {
+		t.Errorf("Expected period 2 cycle back to generation 0 after 2 generations, got %v start %d period %d after %d",
+			result.Reason, result.CycleStart, result.CyclePeriod, result.Generations)
+	}
+
+	life.MaxGenerations = 1
+	result = life.Run(grid, nil)
+	if result.Reason != StopMaxGenerations || result.Grid.GetCell(Position{X: 3, Y: 4}) != '@' {
+		t.Errorf("Expected vertical blinker after 1 generation, got %v", result.Reason)
+	}
+
+	// A hash match is only a cycle if the cells match too: the vertical blinker at
+	// generation 1 must not be taken for generation 0, as a colliding hash would suggest
+	life.MaxGenerations = 0
+	s := newAutomatonState(life, grid)
+	s.step()
+	if prev, ok := life.recurrence(grid, s, []int{0}); ok {
+		t.Errorf("Generation 1 reported as a repeat of generation %d", prev)
+	}
+	s.step()
+	if prev, ok := life.recurrence(grid, s, []int{0}); !ok || prev != 0 {
+		t.Errorf("Generation 2 should repeat generation 0, got %d, %t", prev, ok)
+	}
+}
+
+func TestGetCellEdgePolicies(t *testing.T) {
+	grid := &Grid{
+		Cells: [][]rune{
+			{'a', 'b', 'c'},
+			{'d', 'e', 'f'},
+		},
+		Width:  3,
+		Height: 2,
+	}
+
+	tests := []struct {
+		edge     EdgePolicy
+		pos      Position
+		expected rune
+	}{
+		{EdgeEmpty, Position{X: 0, Y: 1}, '.'},
+		{EdgeWrap, Position{X: 0, Y: 1}, 'f'},  // left of bottom-left wraps to bottom-right
+		{EdgeWrap, Position{X: 4, Y: 3}, 'd'},  // above-right of top-right wraps to bottom-left
+		{EdgeWrap, Position{X: -1, Y: 0}, 'b'}, // wraps on both axes
+		{EdgeReflect, Position{X: 0, Y: 1}, 'd'},
+		{EdgeReflect, Position{X: 4, Y: 3}, 'c'},
+		{EdgeReflect, Position{X: 5, Y: 1}, 'e'},
+		{EdgeFilled, Position{X: 0, Y: 1}, '@'},
+		{EdgeFilled, Position{X: 2, Y: 2}, 'b'}, // in bounds is unaffected
+	}
+
+	for _, tt := range tests {
+		grid.Edge = tt.edge
+		if cell := grid.GetCell(tt.pos); cell != tt.expected {
+			t.Errorf("GetCell(%v) with %v edges = %c, expected %c", tt.pos, tt.edge, cell, tt.expected)
+		}
+	}
+}
+
+func TestEdgePolicyRemovalCounts(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	// Border '@'s see fewer neighbors with empty edges, so they are removed first;
+	// any policy that gives them neighbors beyond the edge protects them
+	tests := []struct {
+		edge           EdgePolicy
+		initial        int
+		completion     int
+		completionRuns int
+	}{
+		{EdgeEmpty, 13, 43, 9},
+		{EdgeWrap, 2, 3, 2},
+		{EdgeReflect, 3, 6, 3},
+		{EdgeFilled, 1, 2, 2},
+	}
+
+	for _, tt := range tests {
+		grid.Edge = tt.edge
+
+		if selected := grid.FindSelectedPositions(); len(selected) != tt.initial {
+			t.Errorf("%v edges: expected %d initial selections, got %d", tt.edge, tt.initial, len(selected))
+		}
+
+		expectedRounds, expectedTotal := RunCompletion(grid, DefaultRule(), func(int, []Position) {})
+		rounds, total := RunCompletionWorklist(grid, DefaultRule(), func(int, []Position) {})
+		if expectedRounds != tt.completionRuns || expectedTotal != tt.completion {
+			t.Errorf("%v edges: expected %d total in %d rounds, got %d in %d",
+				tt.edge, tt.completion, tt.completionRuns, expectedTotal, expectedRounds)
+		}
+		if rounds != expectedRounds || total != expectedTotal {
+			t.Errorf("%v edges: worklist gave %d in %d rounds, rescan gave %d in %d",
+				tt.edge, total, rounds, expectedTotal, expectedRounds)
+		}
+	}
+}
+
+func TestWriteRemovalGIF(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	var rounds [][]Position
+	RunCompletion(grid, DefaultRule(), func(round int, selected []Position) {
+		rounds = append(rounds, selected)
+	})
+
+	var buf bytes.Buffer
+	if err := WriteRemovalGIF(&buf, grid, rounds, '@'); err != nil {
+		t.Fatalf("WriteRemovalGIF failed: %v", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode GIF: %v", err)
+	}
+
+	if len(anim.Image) != len(rounds)+1 {
+		t.Fatalf("Expected %d frames, got %d", len(rounds)+1, len(anim.Image))
+	}
+
+	// [1,1] is removed in round 1: dark in the input frame, round 1's color afterwards
+	scale := cellScale(grid)
+	px, py := 0, (grid.Height-1)*scale
+	if got := anim.Image[0].At(px, py); got != color.Color(remainingColor) {
+		t.Errorf("Frame 0 [1,1] = %v, expected %v", got, remainingColor)
+	}
+	if got := anim.Image[len(rounds)].At(px, py); got != color.Color(roundColor(1, len(rounds))) {
+		t.Errorf("Final frame [1,1] = %v, expected %v", got, roundColor(1, len(rounds)))
+	}
+}
+
+func TestWriteRemovalSVG(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	var rounds [][]Position
+	RunCompletion(grid, DefaultRule(), func(round int, selected []Position) {
+		rounds = append(rounds, selected)
+	})
+
+	var buf bytes.Buffer
+	if err := WriteRemovalSVG(&buf, grid, rounds, '@'); err != nil {
+		t.Fatalf("WriteRemovalSVG failed: %v", err)
+	}
+	svg := buf.String()
+
+	if layers := strings.Count(svg, `<g id="round-`); layers != 9 {
+		t.Errorf("Expected 9 round layers, got %d", layers)
+	}
+	// Round layer rects carry no fill of their own, input rects do
+	if removed := strings.Count(svg, `height="1"/>`); removed != 43 {
+		t.Errorf("Expected 43 removed cells across round layers, got %d", removed)
+	}
+}
+
+func TestDepthMap(t *testing.T) {
+	grid, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	depths := NewDepthMap(grid, '@')
+	RunCompletionWorklist(grid, DefaultRule(), depths.Record)
+
+	perRound, never := depths.Histogram()
+	expected := []int{0, 13, 12, 7, 5, 2, 1, 1, 1, 1}
+	if !slices.Equal(perRound, expected) {
+		t.Errorf("Histogram = %v, expected %v", perRound, expected)
+	}
+	if never != 28 || len(depths.Core()) != 28 {
+		t.Errorf("Expected a stable core of 28, got %d (%d positions)", never, len(depths.Core()))
+	}
+
+	tests := []struct {
+		pos      Position
+		expected int
+	}{
+		{Position{X: 1, Y: 1}, 1},          // removed in the first round
+		{Position{X: 5, Y: 8}, 8},          // deep inside the cascade
+		{Position{X: 5, Y: 1}, DepthNever}, // part of the stable core
+		{Position{X: 2, Y: 1}, DepthEmpty}, // never held '@'
+	}
+	for _, tt := range tests {
+		if depth := depths.Depth(tt.pos); depth != tt.expected {
+			t.Errorf("Depth(%v) = %d, expected %d", tt.pos, depth, tt.expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := depths.WriteGrid(&buf); err != nil {
+		t.Fatalf("WriteGrid failed: %v", err)
+	}
+	if first, _, _ := strings.Cut(buf.String(), "\n"); first != "..11.1121." {
+		t.Errorf("First depth grid row = %q, expected %q", first, "..11.1121.")
+	}
+
+	buf.Reset()
+	if err := depths.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1+43+28 || lines[0] != "x,y,round" || lines[3] != "5,1,never" {
+		t.Errorf("Unexpected CSV: %d lines, starting %v", len(lines), lines[:min(4, len(lines))])
+	}
+}
+
+func TestBitGridGetCellAndCount(t *testing.T) {
+	grid, err := ReadBitGrid(strings.NewReader("@.@\n.@.\n@.@\n"), '@')
+	if err != nil {
+		t.Fatalf("ReadBitGrid failed: %v", err)
+	}
+	if grid.Width != 3 || grid.Height != 3 {
+		t.Fatalf("Expected 3x3 grid, got %dx%d", grid.Width, grid.Height)
+	}
+
+	tests := []struct {
+		pos      Position
+		cell     rune
+		adjacent int
+	}{
+		{Position{X: 1, Y: 3}, '@', 1}, // top-left
+		{Position{X: 2, Y: 3}, '.', 3}, // top-middle
+		{Position{X: 2, Y: 2}, '@', 4}, // center
+		{Position{X: 3, Y: 1}, '@', 1}, // bottom-right
+		{Position{X: 0, Y: 1}, '.', 1}, // out of bounds (left)
+		{Position{X: 1, Y: 4}, '.', 1}, // out of bounds (above)
+	}
+	for _, tt := range tests {
+		if cell := grid.GetCell(tt.pos); cell != tt.cell {
+			t.Errorf("GetCell(%v) = %c, expected %c", tt.pos, cell, tt.cell)
+		}
+		if count := grid.CountAdjacentAt(tt.pos); count != tt.adjacent {
+			t.Errorf("CountAdjacentAt(%v) = %d, expected %d", tt.pos, count, tt.adjacent)
+		}
+	}
+
+	if _, err := ReadBitGrid(strings.NewReader("@@\n@@@\n"), '@'); err == nil {
+		t.Errorf("Expected an error for a line wider than the first")
+	}
+}
+
+func TestBitGridMatchesGrid(t *testing.T) {
+	example, err := NewGridFromFile("example-data.txt")
+	if err != nil {
+		t.Fatalf("Failed to load example-data.txt: %v", err)
+	}
+
+	// A grid wider than a word, so shifts cross word boundaries
+	var rows []string
+	seed := uint32(1)
+	for y := 0; y < 23; y++ {
+		var row strings.Builder
+		for x := 0; x < 150; x++ {
+			seed = seed*1664525 + 1013904223
+			if seed>>24 < 170 {
+				row.WriteByte('@')
+			} else {
+				row.WriteByte('.')
+			}
+		}
+		rows = append(rows, row.String())
+	}
+	wide := &Grid{Width: 150, Height: len(rows)}
+	for _, row := range rows {
+		wide.Cells = append(wide.Cells, []rune(row))
+	}
+
+	rules := []Rule{
+		DefaultRule(),
+		{Symbol: '@', Comparison: LessThan, Threshold: 5, Neighborhood: VonNeumannNeighborhood(2)},
+		{Symbol: '@', Comparison: LessOrEqual, Threshold: 20, Neighborhood: MooreNeighborhood(3)},
+		{Symbol: '@', Comparison: GreaterThan, Threshold: 0, Neighborhood: Neighborhood{{DX: -1}, {DX: 70, DY: 1}, {DX: -65, DY: -2}}},
+		{Symbol: '@', Comparison: NotEqual, Threshold: 3, Neighborhood: MooreNeighborhood(1)},
+	}
+
+	for _, grid := range []*Grid{example, wide} {
+		for _, edge := range []EdgePolicy{EdgeEmpty, EdgeWrap, EdgeReflect, EdgeFilled} {
+			grid.Edge = edge
+			bits := NewBitGridFromGrid(grid, '@')
+
+			for _, rule := range rules {
+				if !slices.Equal(bits.FindSelectedPositionsWith(rule), grid.FindSelectedPositionsWith(rule)) {
+					t.Errorf("%dx%d %v edges, rule %v: initial selections differ", grid.Width, grid.Height, edge, rule)
+				}
+
+				var expected, actual [][]Position
+				RunCompletion(grid, rule, func(round int, selected []Position) {
+					expected = append(expected, selected)
+				})
+				RunCompletionBits(bits, rule, func(round int, selected []Position) {
+					actual = append(actual, selected)
+				})
+				if len(actual) != len(expected) {
+					t.Errorf("%dx%d %v edges, rule %v: %d rounds, expected %d",
+						grid.Width, grid.Height, edge, rule, len(actual), len(expected))
+					continue
+				}
+				for i := range expected {
+					if !slices.Equal(actual[i], expected[i]) {
+						t.Errorf("%dx%d %v edges, rule %v: round %d differs", grid.Width, grid.Height, edge, rule, i+1)
+					}
+				}
+			}
+		}
+	}
+}
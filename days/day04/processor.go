@@ -16,6 +16,8 @@ type Grid struct {
 	Cells  [][]rune // cells[row][col] where row 0 is the top
 	Width  int
 	Height int
+	Edge   EdgePolicy // what out of bounds positions read (default '.')
+	Fill   rune       // symbol read outside the grid under EdgeFilled (default '@')
 }
 
 // NewGridFromFile reads a file and creates a Grid
@@ -55,34 +57,41 @@ func NewGridFromFile(filepath string) (*Grid, error) {
 func (g *Grid) GetCell(pos Position) rune {
 	// Convert 1-based position to 0-based array indices
 	// Y=1 is bottom (last row), Y=Height is top (first row)
-	row := g.Height - pos.Y
-	col := pos.X - 1
+	row, rowOK := resolveAxis(g.Height-pos.Y, g.Height, g.Edge)
+	col, colOK := resolveAxis(pos.X-1, g.Width, g.Edge)
 
-	if row < 0 || row >= g.Height || col < 0 || col >= g.Width {
-		return '.' // out of bounds is treated as empty
+	if !rowOK || !colOK {
+		return g.outside()
 	}
 
 	return g.Cells[row][col]
 }
 
+// outside returns the symbol read for out of bounds positions that do not map back into the grid
+func (g *Grid) outside() rune {
+	if g.Edge != EdgeFilled {
+		return '.' // out of bounds is treated as empty
+	}
+	if g.Fill == 0 {
+		return '@'
+	}
+	return g.Fill
+}
+
+// mooreDirections are the 8 adjacent directions used by the original puzzle rule
+var mooreDirections = MooreNeighborhood(1)
+
 // CountAdjacentAt counts how many '@' symbols are adjacent to the given position
 func (g *Grid) CountAdjacentAt(pos Position) int {
-	// Define the 8 adjacent directions
-	directions := []struct{ dx, dy int }{
-		{-1, 0},  // left
-		{-1, 1},  // left-up
-		{0, 1},   // up
-		{1, 1},   // right-up
-		{1, 0},   // right
-		{1, -1},  // right-down
-		{0, -1},  // down
-		{-1, -1}, // left-down
-	}
+	return g.CountNeighbors(pos, '@', mooreDirections)
+}
 
+// CountNeighbors counts how many cells in the neighborhood of pos hold the given symbol
+func (g *Grid) CountNeighbors(pos Position, symbol rune, neighborhood Neighborhood) int {
 	count := 0
-	for _, dir := range directions {
-		neighbor := Position{X: pos.X + dir.dx, Y: pos.Y + dir.dy}
-		if g.GetCell(neighbor) == '@' {
+	for _, off := range neighborhood {
+		neighbor := Position{X: pos.X + off.DX, Y: pos.Y + off.DY}
+		if g.GetCell(neighbor) == symbol {
 			count++
 		}
 	}
@@ -92,15 +101,20 @@ func (g *Grid) CountAdjacentAt(pos Position) int {
 
 // FindSelectedPositions finds all '@' positions with fewer than 4 adjacent '@' symbols
 func (g *Grid) FindSelectedPositions() []Position {
+	return g.FindSelectedPositionsWith(DefaultRule())
+}
+
+// FindSelectedPositionsWith finds all positions holding the rule's symbol whose
+// neighbor count satisfies the rule
+func (g *Grid) FindSelectedPositionsWith(rule Rule) []Position {
 	var selected []Position
 
 	// Iterate through all positions in the grid (1-based coordinates)
 	for y := 1; y <= g.Height; y++ {
 		for x := 1; x <= g.Width; x++ {
 			pos := Position{X: x, Y: y}
-			if g.GetCell(pos) == '@' {
-				adjacentCount := g.CountAdjacentAt(pos)
-				if adjacentCount < 4 {
+			if g.GetCell(pos) == rule.Symbol {
+				if rule.Matches(g.CountNeighbors(pos, rule.Symbol, rule.Neighborhood)) {
 					selected = append(selected, pos)
 				}
 			}
@@ -134,5 +148,53 @@ func (g *Grid) ReplacePositions(positions []Position) *Grid {
 		Cells:  newCells,
 		Width:  g.Width,
 		Height: g.Height,
+		Edge:   g.Edge,
+		Fill:   g.Fill,
+	}
+}
+
+// cellIndex maps positions to flat cell indices in scan order: (Y-1)*width + (X-1),
+// so index 0 is the bottom-left cell and ascending indices match FindSelectedPositions
+type cellIndex struct {
+	width  int
+	height int
+	edge   EdgePolicy
+}
+
+func newCellIndex(g *Grid) cellIndex {
+	return cellIndex{width: g.Width, height: g.Height, edge: g.Edge}
+}
+
+// position converts a cell index into a 1-based bottom-left Position
+func (c cellIndex) position(i int) Position {
+	return Position{X: i%c.width + 1, Y: i/c.width + 1}
+}
+
+// index converts a Position into the index of the cell it reads under the edge
+// policy, reporting false when it reads the outside symbol instead
+func (c cellIndex) index(pos Position) (int, bool) {
+	x, xOK := resolveAxis(pos.X-1, c.width, c.edge)
+	y, yOK := resolveAxis(pos.Y-1, c.height, c.edge)
+	if !xOK || !yOK {
+		return 0, false
+	}
+	return y*c.width + x, true
+}
+
+// dependents appends every cell that reads cell i through its neighborhood to buf.
+// A cell reading i through several offsets (possible when edges wrap or reflect) is
+// appended once per offset.
+func (c cellIndex) dependents(i int, neighborhood Neighborhood, buf []int) []int {
+	x, y := i%c.width, i/c.width
+	var xs, ys [4]int
+	for _, off := range neighborhood {
+		// Cell i is the neighbor at offset off of the cells whose coordinates
+		// displaced by off resolve to (x, y)
+		for _, nx := range axisSources(x, off.DX, c.width, c.edge, xs[:0]) {
+			for _, ny := range axisSources(y, off.DY, c.height, c.edge, ys[:0]) {
+				buf = append(buf, ny*c.width+nx)
+			}
+		}
 	}
+	return buf
 }
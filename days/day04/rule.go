@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Comparison is the relational operator used to test a neighbor count against a threshold
+type Comparison int
+
+const (
+	LessThan Comparison = iota
+	LessOrEqual
+	Equal
+	NotEqual
+	GreaterOrEqual
+	GreaterThan
+)
+
+var comparisonSymbols = map[Comparison]string{
+	LessThan:       "<",
+	LessOrEqual:    "<=",
+	Equal:          "==",
+	NotEqual:       "!=",
+	GreaterOrEqual: ">=",
+	GreaterThan:    ">",
+}
+
+// ParseComparison converts an operator such as "<" or ">=" into a Comparison
+func ParseComparison(s string) (Comparison, error) {
+	switch s {
+	case "<", "lt":
+		return LessThan, nil
+	case "<=", "le":
+		return LessOrEqual, nil
+	case "=", "==", "eq":
+		return Equal, nil
+	case "!=", "ne":
+		return NotEqual, nil
+	case ">=", "ge":
+		return GreaterOrEqual, nil
+	case ">", "gt":
+		return GreaterThan, nil
+	}
+	return LessThan, fmt.Errorf("unknown comparison %q", s)
+}
+
+// String returns the operator symbol for the comparison
+func (c Comparison) String() string {
+	if s, ok := comparisonSymbols[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("Comparison(%d)", int(c))
+}
+
+// Compare reports whether count satisfies the comparison against threshold
+func (c Comparison) Compare(count, threshold int) bool {
+	switch c {
+	case LessThan:
+		return count < threshold
+	case LessOrEqual:
+		return count <= threshold
+	case Equal:
+		return count == threshold
+	case NotEqual:
+		return count != threshold
+	case GreaterOrEqual:
+		return count >= threshold
+	case GreaterThan:
+		return count > threshold
+	}
+	return false
+}
+
+// Offset is a displacement relative to a position (positive DY is up)
+type Offset struct {
+	DX int
+	DY int
+}
+
+// Neighborhood is the set of offsets treated as adjacent to a position
+type Neighborhood []Offset
+
+// MooreNeighborhood returns every offset within the given Chebyshev distance (8 cells for radius 1)
+func MooreNeighborhood(radius int) Neighborhood {
+	var n Neighborhood
+	for dy := radius; dy >= -radius; dy-- {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx != 0 || dy != 0 {
+				n = append(n, Offset{DX: dx, DY: dy})
+			}
+		}
+	}
+	return n
+}
+
+// VonNeumannNeighborhood returns every offset within the given Manhattan distance (4 cells for radius 1)
+func VonNeumannNeighborhood(radius int) Neighborhood {
+	var n Neighborhood
+	for dy := radius; dy >= -radius; dy-- {
+		for dx := -radius; dx <= radius; dx++ {
+			if (dx != 0 || dy != 0) && abs(dx)+abs(dy) <= radius {
+				n = append(n, Offset{DX: dx, DY: dy})
+			}
+		}
+	}
+	return n
+}
+
+// ParseNeighborhood builds a Neighborhood from a specification such as
+// "moore", "moore:2", "vonneumann", "vonneumann:3" or "custom:-1,0;1,0;0,1"
+func ParseNeighborhood(spec string) (Neighborhood, error) {
+	kind, arg, hasArg := strings.Cut(spec, ":")
+
+	switch strings.ToLower(kind) {
+	case "moore", "vonneumann", "von-neumann":
+		radius := 1
+		if hasArg {
+			r, err := strconv.Atoi(arg)
+			if err != nil || r < 1 {
+				return nil, fmt.Errorf("invalid neighborhood radius %q", arg)
+			}
+			radius = r
+		}
+		if strings.ToLower(kind) == "moore" {
+			return MooreNeighborhood(radius), nil
+		}
+		return VonNeumannNeighborhood(radius), nil
+
+	case "custom":
+		if !hasArg || strings.TrimSpace(arg) == "" {
+			return nil, fmt.Errorf("custom neighborhood requires offsets, e.g. custom:-1,0;1,0")
+		}
+		var n Neighborhood
+		seen := make(map[Offset]bool)
+		for _, part := range strings.Split(arg, ";") {
+			xs, ys, ok := strings.Cut(strings.TrimSpace(part), ",")
+			if !ok {
+				return nil, fmt.Errorf("invalid offset %q: expected dx,dy", part)
+			}
+			dx, errX := strconv.Atoi(strings.TrimSpace(xs))
+			dy, errY := strconv.Atoi(strings.TrimSpace(ys))
+			if errX != nil || errY != nil {
+				return nil, fmt.Errorf("invalid offset %q: expected integers", part)
+			}
+			off := Offset{DX: dx, DY: dy}
+			if off == (Offset{}) {
+				return nil, fmt.Errorf("offset 0,0 refers to the cell itself")
+			}
+			if seen[off] {
+				return nil, fmt.Errorf("duplicate offset %q", part)
+			}
+			seen[off] = true
+			n = append(n, off)
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unknown neighborhood %q: must be 'moore', 'vonneumann' or 'custom'", kind)
+}
+
+// Rule describes which cells are selected: cells holding Symbol whose count of
+// Symbol neighbors satisfies Comparison against Threshold
+type Rule struct {
+	Symbol       rune
+	Comparison   Comparison
+	Threshold    int
+	Neighborhood Neighborhood
+}
+
+// DefaultRule returns the original puzzle rule: an '@' with fewer than 4 of its 8 neighbors being '@'
+func DefaultRule() Rule {
+	return Rule{
+		Symbol:       '@',
+		Comparison:   LessThan,
+		Threshold:    4,
+		Neighborhood: MooreNeighborhood(1),
+	}
+}
+
+// Matches reports whether a neighbor count satisfies the rule
+func (r Rule) Matches(count int) bool {
+	return r.Comparison.Compare(count, r.Threshold)
+}
+
+// ParseSymbol converts a single-character string into a rune
+func ParseSymbol(s string) (rune, error) {
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("symbol %q must be a single character", s)
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+)
+
+// Visualization colors: empty cells, cells still holding the symbol and any other symbols
+var (
+	emptyColor     = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	remainingColor = color.RGBA{0x40, 0x40, 0x40, 0xff}
+	otherColor     = color.RGBA{0xc8, 0xc8, 0xc8, 0xff}
+)
+
+// maxRoundColors is how many distinct round colors fit in a GIF palette alongside the fixed colors
+const maxRoundColors = 250
+
+// frameDelay is the GIF delay between rounds in 100ths of a second
+const frameDelay = 40
+
+// roundColor picks a color along a red to blue gradient for round r of total
+func roundColor(r, total int) color.RGBA {
+	t := 0.0
+	if total > 1 {
+		t = float64(r-1) / float64(total-1)
+	}
+	return hsvColor(240*t, 0.85, 0.95)
+}
+
+// hsvColor converts a hue in degrees and saturation/value in [0,1] into RGB
+func hsvColor(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 0xff,
+	}
+}
+
+// cellScale picks a pixel size per cell that keeps the image around 800 pixels
+func cellScale(grid *Grid) int {
+	return max(1, min(10, 800/max(grid.Width, grid.Height, 1)))
+}
+
+// WriteRemovalGIF writes an animated GIF with one frame for the input grid and one per
+// round. Removed cells keep the color of the round they were removed in.
+func WriteRemovalGIF(w io.Writer, grid *Grid, rounds [][]Position, symbol rune) error {
+	depths := NewDepthMap(grid, symbol)
+	for r, positions := range rounds {
+		depths.Record(r+1, positions)
+	}
+	scale := cellScale(grid)
+
+	// Long runs share colors so the palette stays within 256 entries
+	colors := min(len(rounds), maxRoundColors)
+	palette := color.Palette{emptyColor, remainingColor, otherColor}
+	for c := 1; c <= colors; c++ {
+		palette = append(palette, roundColor(c, colors))
+	}
+	roundIndex := func(r int) uint8 {
+		return uint8(3 + (r-1)*colors/len(rounds))
+	}
+
+	anim := &gif.GIF{}
+	for frame := 0; frame <= len(rounds); frame++ {
+		img := image.NewPaletted(image.Rect(0, 0, grid.Width*scale, grid.Height*scale), palette)
+		for row := 0; row < grid.Height; row++ {
+			for col := 0; col < grid.Width; col++ {
+				var idx uint8
+				depth := depths.Depth(Position{X: col + 1, Y: grid.Height - row})
+				switch {
+				case depth > 0 && depth <= frame:
+					idx = roundIndex(depth)
+				case grid.Cells[row][col] == symbol:
+					idx = 1
+				case grid.Cells[row][col] != '.':
+					idx = 2
+				default:
+					continue
+				}
+				for py := row * scale; py < (row+1)*scale; py++ {
+					for px := col * scale; px < (col+1)*scale; px++ {
+						img.SetColorIndex(px, py, idx)
+					}
+				}
+			}
+		}
+
+		delay := frameDelay
+		if frame == len(rounds) {
+			delay = frameDelay * 5 // linger on the final state
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// WriteRemovalSVG writes an SVG of the input grid with one layer (<g id="round-N">) per
+// round. Each layer is hidden at first and revealed in turn, animating the removals.
+func WriteRemovalSVG(w io.Writer, grid *Grid, rounds [][]Position, symbol rune) error {
+	bw := bufio.NewWriter(w)
+	scale := cellScale(grid)
+	seconds := float64(frameDelay) / 100
+
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" shape-rendering=\"crispEdges\">\n",
+		grid.Width*scale, grid.Height*scale, grid.Width, grid.Height)
+	fmt.Fprintf(bw, "  <rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", hexColor(emptyColor))
+
+	// Base layer: the input grid
+	fmt.Fprintf(bw, "  <g id=\"input\">\n")
+	for row := 0; row < grid.Height; row++ {
+		for col := 0; col < grid.Width; col++ {
+			fill := remainingColor
+			switch grid.Cells[row][col] {
+			case symbol:
+			case '.':
+				continue
+			default:
+				fill = otherColor
+			}
+			fmt.Fprintf(bw, "    <rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\" fill=\"%s\"/>\n", col, row, hexColor(fill))
+		}
+	}
+	fmt.Fprintf(bw, "  </g>\n")
+
+	// One layer per round, revealed in order
+	for r, positions := range rounds {
+		round := r + 1
+		fmt.Fprintf(bw, "  <g id=\"round-%d\" fill=\"%s\" opacity=\"0\">\n", round, hexColor(roundColor(round, len(rounds))))
+		fmt.Fprintf(bw, "    <title>Round %d: %d removed</title>\n", round, len(positions))
+		fmt.Fprintf(bw, "    <set attributeName=\"opacity\" to=\"1\" begin=\"%.2fs\" fill=\"freeze\"/>\n", float64(round)*seconds)
+		for _, pos := range positions {
+			fmt.Fprintf(bw, "    <rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\"/>\n", pos.X-1, grid.Height-pos.Y)
+		}
+		fmt.Fprintf(bw, "  </g>\n")
+	}
+
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
+
+// writeRemovalFile writes a removal visualization to path with the given writer function
+func writeRemovalFile(path string, write func(io.Writer, *Grid, [][]Position, rune) error, grid *Grid, rounds [][]Position, symbol rune) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, grid, rounds, symbol); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RoundFunc receives the positions selected in a completion round (rounds are 1-based)
+type RoundFunc func(round int, selected []Position)
+
+// Completion engine names accepted by RunCompletionWith, plus EngineBitset which
+// runs on a BitGrid (see RunCompletionBits)
+const (
+	EngineRescan   = "rescan"
+	EngineWorklist = "worklist"
+	EngineBitset   = "bitset"
+)
+
+// RunCompletionWith runs completion mode with the named engine
+func RunCompletionWith(engine string, grid *Grid, rule Rule, onRound RoundFunc) (rounds, total int, err error) {
+	switch engine {
+	case EngineRescan:
+		rounds, total = RunCompletion(grid, rule, onRound)
+	case EngineWorklist:
+		rounds, total = RunCompletionWorklist(grid, rule, onRound)
+	default:
+		err = fmt.Errorf("unknown engine %q: must be '%s' or '%s'", engine, EngineRescan, EngineWorklist)
+	}
+	return rounds, total, err
+}
+
+// RunCompletion repeatedly selects positions over the whole grid and replaces them
+// with '.' until nothing is selected, returning the number of rounds and total selections
+func RunCompletion(grid *Grid, rule Rule, onRound RoundFunc) (rounds, total int) {
+	for {
+		selected := grid.FindSelectedPositionsWith(rule)
+		if len(selected) == 0 {
+			return rounds, total
+		}
+
+		rounds++
+		total += len(selected)
+		onRound(rounds, selected)
+
+		grid = grid.ReplacePositions(selected)
+	}
+}
+
+// RunCompletionWorklist produces the same rounds as RunCompletion, but keeps a neighbor
+// count per cell and after each round only re-evaluates cells whose count changed
+func RunCompletionWorklist(grid *Grid, rule Rule, onRound RoundFunc) (rounds, total int) {
+	w := newWorklist(grid, rule)
+
+	// Every live cell is a candidate in the first round
+	var candidates []int
+	for i, alive := range w.alive {
+		if alive {
+			candidates = append(candidates, i)
+		}
+	}
+
+	for {
+		var removed []int
+		for _, i := range candidates {
+			w.queued[i] = false
+			if w.alive[i] && rule.Matches(int(w.counts[i])) {
+				removed = append(removed, i)
+			}
+		}
+		if len(removed) == 0 {
+			return rounds, total
+		}
+
+		// Indices follow scan order, so sorting them reports positions in the
+		// same bottom-to-top, left-to-right order as FindSelectedPositions
+		slices.Sort(removed)
+		selected := make([]Position, len(removed))
+		for k, i := range removed {
+			selected[k] = w.position(i)
+		}
+
+		rounds++
+		total += len(selected)
+		onRound(rounds, selected)
+
+		// Remove the whole round before re-evaluating so updates stay synchronous
+		for _, i := range removed {
+			w.alive[i] = false
+		}
+		candidates = candidates[:0]
+		for _, i := range removed {
+			candidates = w.release(i, candidates)
+		}
+	}
+}
+
+// worklist holds the per-cell state used by RunCompletionWorklist
+type worklist struct {
+	cellIndex
+	neighborhood Neighborhood
+	alive        []bool
+	counts       []int32
+	queued       []bool
+	scratch      []int
+}
+
+func newWorklist(grid *Grid, rule Rule) *worklist {
+	size := grid.Width * grid.Height
+	w := &worklist{
+		cellIndex:    newCellIndex(grid),
+		neighborhood: rule.Neighborhood,
+		alive:        make([]bool, size),
+		counts:       make([]int32, size),
+		queued:       make([]bool, size),
+	}
+
+	for i := range w.alive {
+		w.alive[i] = grid.GetCell(w.position(i)) == rule.Symbol
+	}
+	for i := range w.counts {
+		w.counts[i] = int32(grid.CountNeighbors(w.position(i), rule.Symbol, rule.Neighborhood))
+	}
+
+	return w
+}
+
+// release decrements the count of every cell that has the removed cell i in its
+// neighborhood and appends the live ones not yet queued to candidates
+func (w *worklist) release(i int, candidates []int) []int {
+	w.scratch = w.dependents(i, w.neighborhood, w.scratch[:0])
+	for _, j := range w.scratch {
+		w.counts[j]--
+		if w.alive[j] && !w.queued[j] {
+			w.queued[j] = true
+			candidates = append(candidates, j)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Union returns the ranges covered by either list, merged
+func (rl *RangeList) Union(other *RangeList) *RangeList {
+	combined := make([]Range, 0, len(rl.Ranges)+len(other.Ranges))
+	combined = append(combined, rl.Ranges...)
+	combined = append(combined, other.Ranges...)
+	return &RangeList{Ranges: mergeRanges(combined)}
+}
+
+// Intersection returns the ranges covered by both lists, merged
+func (rl *RangeList) Intersection(other *RangeList) *RangeList {
+	a, b := rl.Index().Ranges, other.Index().Ranges
+	result := &RangeList{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if start, end := max(a[i].Start, b[j].Start), min(a[i].End, b[j].End); start <= end {
+			result.Ranges = append(result.Ranges, Range{Start: start, End: end})
+		}
+		// Whichever range ends first cannot overlap anything further in the other list
+		if a[i].End < b[j].End {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// Difference returns the ranges covered by this list but not by other, merged
+func (rl *RangeList) Difference(other *RangeList) *RangeList {
+	return &RangeList{Ranges: subtractRanges(rl.Index().Ranges, other.Index().Ranges)}
+}
+
+// Complement returns the parts of bounds not covered by the list, merged
+func (rl *RangeList) Complement(bounds Range) *RangeList {
+	if bounds.Start > bounds.End {
+		return &RangeList{}
+	}
+	return &RangeList{Ranges: subtractRanges([]Range{bounds}, rl.Index().Ranges)}
+}
+
+// subtractRanges removes the merged ranges b from the merged ranges a
+func subtractRanges(a, b []Range) []Range {
+	var result []Range
+	j := 0
+	for _, r := range a {
+		// Skip ranges of b that end before r starts; they cannot overlap later ranges of a either
+		for j < len(b) && b[j].End < r.Start {
+			j++
+		}
+
+		start := r.Start
+		covered := false
+		for k := j; k < len(b) && b[k].Start <= r.End; k++ {
+			if b[k].Start > start {
+				result = append(result, Range{Start: start, End: b[k].Start - 1})
+			}
+			if b[k].End >= r.End {
+				covered = true
+				break
+			}
+			start = b[k].End + 1
+		}
+		if !covered {
+			result = append(result, Range{Start: start, End: r.End})
+		}
+	}
+	return result
+}
+
+// rangeAlgebra applies a set operation to rangeList; arg is the other range file, or
+// the bounding range for complement
+func rangeAlgebra(rangeList *RangeList, op, arg string) (*RangeList, error) {
+	if op == "complement" {
+		bounds, err := parseRange(arg)
+		if err != nil {
+			return nil, fmt.Errorf("parsing bounding range '%s': %w", arg, err)
+		}
+		return rangeList.Complement(bounds), nil
+	}
+
+	other, _, err := readInput(arg)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "union":
+		return rangeList.Union(other), nil
+	case "intersect":
+		return rangeList.Intersection(other), nil
+	case "difference":
+		return rangeList.Difference(other), nil
+	}
+	return nil, fmt.Errorf("unknown set operation: %s", op)
+}
+
+// writeRanges writes ranges one per line in the input format
+func writeRanges(w io.Writer, ranges []Range) error {
+	bw := bufio.NewWriter(w)
+	for _, r := range ranges {
+		fmt.Fprintf(bw, "%d-%d\n", r.Start, r.End)
+	}
+	return bw.Flush()
+}
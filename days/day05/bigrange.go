@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// BigRange is an inclusive range whose bounds may exceed int64. A nil Start or End is an
+// open end: the range has no lower or upper bound.
+type BigRange struct {
+	Start *big.Int
+	End   *big.Int
+}
+
+// NewBigRange converts an int64 Range into a BigRange
+func NewBigRange(r Range) BigRange {
+	return BigRange{Start: big.NewInt(r.Start), End: big.NewInt(r.End)}
+}
+
+func (r BigRange) Contains(n *big.Int) bool {
+	return (r.Start == nil || n.Cmp(r.Start) >= 0) && (r.End == nil || n.Cmp(r.End) <= 0)
+}
+
+// Unbounded reports whether the range has an open end
+func (r BigRange) Unbounded() bool {
+	return r.Start == nil || r.End == nil
+}
+
+// Size returns End-Start+1, or nil if the range is unbounded
+func (r BigRange) Size() *big.Int {
+	if r.Unbounded() {
+		return nil
+	}
+	size := new(big.Int).Sub(r.End, r.Start)
+	return size.Add(size, big.NewInt(1))
+}
+
+// String writes the range as a-b, or with ".." for an open end (5.. or ..5), so it can be
+// read back by parseBigRange
+func (r BigRange) String() string {
+	switch {
+	case r.Start == nil && r.End == nil:
+		return ".."
+	case r.Start == nil:
+		return fmt.Sprintf("..%s", r.End)
+	case r.End == nil:
+		return fmt.Sprintf("%s..", r.Start)
+	}
+	return fmt.Sprintf("%s-%s", r.Start, r.End)
+}
+
+// compareStarts orders range starts, an open start first
+func compareStarts(a, b *big.Int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	return a.Cmp(b)
+}
+
+// compareEnds orders range ends, an open end last
+func compareEnds(a, b *big.Int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+	return a.Cmp(b)
+}
+
+// BigRangeList is the math/big counterpart of RangeList, used when a bound or number in
+// the input does not fit in int64
+type BigRangeList struct {
+	Ranges []BigRange
+	merged []BigRange
+	source int // number of ranges merged is built from
+}
+
+// NewBigRangeList promotes the ranges of a RangeList
+func NewBigRangeList(rl *RangeList) *BigRangeList {
+	bl := &BigRangeList{}
+	for _, r := range rl.Ranges {
+		bl.AddRange(NewBigRange(r))
+	}
+	return bl
+}
+
+func (bl *BigRangeList) AddRange(r BigRange) {
+	bl.Ranges = append(bl.Ranges, r)
+}
+
+// Merged returns the ranges sorted by start with overlapping and adjacent ranges merged
+func (bl *BigRangeList) Merged() []BigRange {
+	if bl.merged != nil && bl.source == len(bl.Ranges) {
+		return bl.merged
+	}
+
+	sorted := slices.Clone(bl.Ranges)
+	slices.SortFunc(sorted, func(a, b BigRange) int {
+		return compareStarts(a.Start, b.Start)
+	})
+
+	var merged []BigRange
+	one := big.NewInt(1)
+	for _, r := range sorted {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			if last.End == nil || compareStarts(r.Start, new(big.Int).Add(last.End, one)) <= 0 {
+				// Overlapping or adjacent - extend the last merged range
+				if compareEnds(r.End, last.End) > 0 {
+					last.End = r.End
+				}
+				continue
+			}
+		}
+		merged = append(merged, BigRange{Start: r.Start, End: r.End})
+	}
+
+	bl.merged, bl.source = merged, len(bl.Ranges)
+	return merged
+}
+
+func (bl *BigRangeList) IsValid(n *big.Int) bool {
+	merged := bl.Merged()
+	i := sort.Search(len(merged), func(i int) bool {
+		return compareEnds(merged[i].End, n) >= 0
+	})
+	return i < len(merged) && merged[i].Contains(n)
+}
+
+// RangesContaining returns the positions in Ranges of every range containing n
+func (bl *BigRangeList) RangesContaining(n *big.Int) []int {
+	var positions []int
+	for i, r := range bl.Ranges {
+		if r.Contains(n) {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// CountTotalValid returns the number of valid numbers, or nil if there are infinitely
+// many because a range is unbounded
+func (bl *BigRangeList) CountTotalValid() *big.Int {
+	total := new(big.Int)
+	for _, r := range bl.Merged() {
+		if r.Unbounded() {
+			return nil
+		}
+		total.Add(total, r.Size())
+	}
+	return total
+}
+
+type BigNumberList struct {
+	Numbers []*big.Int
+}
+
+func (nl *BigNumberList) AddNumber(n *big.Int) {
+	nl.Numbers = append(nl.Numbers, n)
+}
+
+func (nl *BigNumberList) ValidateAgainstRanges(rangeList *BigRangeList) int64 {
+	var count int64 = 0
+	for _, num := range nl.Numbers {
+		valid := rangeList.IsValid(num)
+		if valid {
+			var parts []string
+			for _, pos := range rangeList.RangesContaining(num) {
+				parts = append(parts, fmt.Sprintf("line %d: %s", pos+1, rangeList.Ranges[pos]))
+			}
+			fmt.Printf("%s: %t (%s)\n", num, valid, strings.Join(parts, ", "))
+			count++
+		} else {
+			fmt.Printf("%s: %t\n", num, valid)
+		}
+	}
+	return count
+}
+
+// parseBigInt parses a base 10 integer of any size
+func parseBigInt(s string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(strings.TrimSpace(s), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid number: %s", s)
+	}
+	return n, nil
+}
+
+// parseBigRange parses a range like parseRange, with math/big bounds. Unlike parseRange,
+// which stops open ends at the int64 limits, an open end is left unbounded (nil).
+func parseBigRange(line string) (BigRange, error) {
+	b, err := splitRange(line)
+	if err != nil {
+		return BigRange{}, err
+	}
+
+	var r BigRange
+	if b.start != "" {
+		if r.Start, err = parseBigInt(b.start); err != nil {
+			return BigRange{}, err
+		}
+	}
+	if b.end != "" {
+		if r.End, err = parseBigInt(b.end); err != nil {
+			return BigRange{}, err
+		}
+	}
+
+	one := big.NewInt(1)
+	if b.startExclusive && b.start != "" {
+		r.Start.Add(r.Start, one)
+	}
+	if b.endExclusive && b.end != "" {
+		r.End.Sub(r.End, one)
+	}
+	if !r.Unbounded() && r.Start.Cmp(r.End) > 0 {
+		return BigRange{}, fmt.Errorf("empty range: %s", line)
+	}
+	return r, nil
+}
+
+// readBigInput reads an input file like readInput, with math/big bounds and numbers
+func readBigInput(filePath string) (*BigRangeList, *BigNumberList, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	rangeList, err := readBigRanges(scanner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	numberList := &BigNumberList{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		num, err := parseBigInt(line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing number '%s': %w", line, err)
+		}
+		numberList.AddNumber(num)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading file: %w", err)
+	}
+	return rangeList, numberList, nil
+}
+
+// readBigRanges reads math/big ranges up to the first blank line
+func readBigRanges(scanner *bufio.Scanner) (*BigRangeList, error) {
+	rangeList := &BigRangeList{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			break
+		}
+		r, err := parseBigRange(line)
+		if err != nil {
+			return nil, fmt.Errorf("parsing range '%s': %w", line, err)
+		}
+		rangeList.AddRange(r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return rangeList, nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// DynamicRangeList is a long-lived set of ranges that supports removal as well as
+// addition. The covered values are kept as disjoint, non-adjacent intervals in a treap
+// (a randomized balanced binary search tree) keyed by start, and the total is updated
+// with every change instead of being recomputed.
+type DynamicRangeList struct {
+	root  *intervalNode
+	count int
+	// total is the number of covered values modulo 2^64; it is exact except when every
+	// int64 is covered, which needs 2^64
+	total uint64
+}
+
+type intervalNode struct {
+	Range
+	priority    uint32
+	left, right *intervalNode
+}
+
+// NewDynamicRangeList creates a DynamicRangeList covering the ranges of rl
+func NewDynamicRangeList(rl *RangeList) *DynamicRangeList {
+	dl := &DynamicRangeList{}
+	for _, r := range rl.Index().Ranges {
+		dl.AddRange(r)
+	}
+	return dl
+}
+
+// AddRange adds the values of r, merging it with any intervals it overlaps or touches
+func (dl *DynamicRangeList) AddRange(r Range) {
+	if r.Start > r.End {
+		return
+	}
+
+	// The interval starting before r may overlap or touch it
+	left, right := splitBefore(dl.root, r.Start)
+	if last := maxNode(left); last != nil && (r.Start == math.MinInt64 || last.End >= r.Start-1) {
+		left = dl.detach(left, last)
+		r.Start = last.Start
+		r.End = max(r.End, last.End)
+	}
+
+	// Every interval starting inside r, or right after it, is absorbed
+	var absorbed *intervalNode
+	if r.End >= math.MaxInt64-1 {
+		absorbed, right = right, nil
+	} else {
+		absorbed, right = splitBefore(right, r.End+2)
+	}
+	if last := maxNode(absorbed); last != nil {
+		r.End = max(r.End, last.End)
+	}
+	dl.discard(absorbed)
+
+	dl.root = mergeNodes(mergeNodes(left, dl.newNode(r)), right)
+}
+
+// RemoveRange removes the values of r, splitting any interval that extends past it
+func (dl *DynamicRangeList) RemoveRange(r Range) {
+	if r.Start > r.End {
+		return
+	}
+
+	var pieces []Range
+	left, right := splitBefore(dl.root, r.Start)
+	if last := maxNode(left); last != nil && last.End >= r.Start {
+		left = dl.detach(left, last)
+		pieces = append(pieces, Range{Start: last.Start, End: r.Start - 1})
+		if last.End > r.End {
+			pieces = append(pieces, Range{Start: r.End + 1, End: last.End})
+		}
+	}
+
+	var removed *intervalNode
+	if r.End == math.MaxInt64 {
+		removed, right = right, nil
+	} else {
+		removed, right = splitBefore(right, r.End+1)
+	}
+	if last := maxNode(removed); last != nil && last.End > r.End {
+		pieces = append(pieces, Range{Start: r.End + 1, End: last.End})
+	}
+	dl.discard(removed)
+
+	dl.root = mergeNodes(left, right)
+	for _, piece := range pieces {
+		dl.AddRange(piece)
+	}
+}
+
+// IsValid reports whether n is covered
+func (dl *DynamicRangeList) IsValid(n int64) bool {
+	// Find the interval with the greatest start not after n
+	var floor *intervalNode
+	for node := dl.root; node != nil; {
+		if node.Start <= n {
+			floor = node
+			node = node.right
+		} else {
+			node = node.left
+		}
+	}
+	return floor != nil && floor.End >= n
+}
+
+// CountTotalValid returns the number of covered values, or math.MaxInt64 when that does
+// not fit in int64 (see TotalBig)
+func (dl *DynamicRangeList) CountTotalValid() int64 {
+	if dl.full() || dl.total > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(dl.total)
+}
+
+// TotalBig returns the exact number of covered values
+func (dl *DynamicRangeList) TotalBig() *big.Int {
+	total := new(big.Int).SetUint64(dl.total)
+	if dl.full() {
+		total.Lsh(big.NewInt(1), 64)
+	}
+	return total
+}
+
+// full reports whether every int64 is covered
+func (dl *DynamicRangeList) full() bool {
+	return dl.count == 1 && dl.root.Start == math.MinInt64 && dl.root.End == math.MaxInt64
+}
+
+// Len returns the number of disjoint intervals
+func (dl *DynamicRangeList) Len() int {
+	return dl.count
+}
+
+// Ranges returns the disjoint intervals sorted by start
+func (dl *DynamicRangeList) Ranges() []Range {
+	ranges := make([]Range, 0, dl.count)
+	var walk func(*intervalNode)
+	walk = func(node *intervalNode) {
+		if node == nil {
+			return
+		}
+		walk(node.left)
+		ranges = append(ranges, node.Range)
+		walk(node.right)
+	}
+	walk(dl.root)
+	return ranges
+}
+
+func (dl *DynamicRangeList) newNode(r Range) *intervalNode {
+	dl.count++
+	dl.total += intervalSize(r)
+	return &intervalNode{Range: r, priority: rand.Uint32()}
+}
+
+// detach removes node, the last interval of tree, and returns the rest of the tree
+func (dl *DynamicRangeList) detach(tree, node *intervalNode) *intervalNode {
+	dl.count--
+	dl.total -= intervalSize(node.Range)
+	rest, _ := splitBefore(tree, node.Start)
+	return rest
+}
+
+// discard drops every interval of a detached subtree from the count and total
+func (dl *DynamicRangeList) discard(node *intervalNode) {
+	if node == nil {
+		return
+	}
+	dl.count--
+	dl.total -= intervalSize(node.Range)
+	dl.discard(node.left)
+	dl.discard(node.right)
+}
+
+// intervalSize returns End-Start+1 modulo 2^64
+func intervalSize(r Range) uint64 {
+	return uint64(r.End) - uint64(r.Start) + 1
+}
+
+// splitBefore splits a tree into the intervals starting before key and the rest
+func splitBefore(node *intervalNode, key int64) (*intervalNode, *intervalNode) {
+	if node == nil {
+		return nil, nil
+	}
+	if node.Start < key {
+		var right *intervalNode
+		node.right, right = splitBefore(node.right, key)
+		return node, right
+	}
+	left, rest := splitBefore(node.left, key)
+	node.left = rest
+	return left, node
+}
+
+// mergeNodes joins two trees where every start in a is before every start in b
+func mergeNodes(a, b *intervalNode) *intervalNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.priority > b.priority:
+		a.right = mergeNodes(a.right, b)
+		return a
+	default:
+		b.left = mergeNodes(a, b.left)
+		return b
+	}
+}
+
+func maxNode(node *intervalNode) *intervalNode {
+	for node != nil && node.right != nil {
+		node = node.right
+	}
+	return node
+}
+
+// runSession applies commands read from r to dl, one per line:
+//
+//	add <start-end>     add a range
+//	remove <start-end>  remove a range
+//	query <n>           print whether n is valid
+//	total               print the total number of valid numbers
+//	ranges              print the disjoint ranges
+func runSession(w io.Writer, r io.Reader, dl *DynamicRangeList) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		command, arg := fields[0], ""
+		if len(fields) > 1 {
+			arg = fields[1]
+		}
+		needsArg := command == "add" || command == "remove" || command == "query"
+		if len(fields) > 2 || needsArg != (len(fields) == 2) {
+			return fmt.Errorf("line %d: invalid command: %s", line, scanner.Text())
+		}
+
+		switch command {
+		case "add", "remove":
+			rg, err := parseRange(arg)
+			if err != nil {
+				return fmt.Errorf("line %d: parsing range '%s': %w", line, arg, err)
+			}
+			if command == "add" {
+				dl.AddRange(rg)
+			} else {
+				dl.RemoveRange(rg)
+			}
+		case "query":
+			n, err := strconv.ParseInt(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("line %d: parsing number '%s': %w", line, arg, err)
+			}
+			fmt.Fprintf(w, "%d: %t\n", n, dl.IsValid(n))
+		case "total":
+			fmt.Fprintf(w, "Total possible valid numbers: %s\n", dl.TotalBig())
+		case "ranges":
+			if err := writeRanges(w, dl.Ranges()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("line %d: unknown command: %s", line, command)
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"math/big"
+	"slices"
+	"sort"
+)
+
+// RangeIndex holds the merged form of a RangeList: disjoint, non-adjacent ranges sorted
+// by start, so membership is a binary search and the total is a running sum
+type RangeIndex struct {
+	Ranges   []Range
+	total    int64
+	overflow bool // the total does not fit in int64
+	source   int  // number of input ranges the index was built from
+}
+
+// NewRangeIndex sorts and merges ranges into a RangeIndex; the input is not modified
+func NewRangeIndex(ranges []Range) *RangeIndex {
+	ix := &RangeIndex{Ranges: mergeRanges(ranges), source: len(ranges)}
+	for _, r := range ix.Ranges {
+		// A range spanning more than half of int64 has a size that does not fit, and
+		// the sum of sizes can overflow near the maximum
+		span := r.End - r.Start
+		if (span < 0 && r.Start < 0 && r.End >= 0) || span == math.MaxInt64 || ix.total > math.MaxInt64-span-1 {
+			ix.overflow = true
+			break
+		}
+		ix.total += span + 1
+	}
+	return ix
+}
+
+// mergeRanges returns a sorted copy of ranges with overlapping and adjacent ranges merged
+func mergeRanges(ranges []Range) []Range {
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	sorted := make([]Range, len(ranges))
+	copy(sorted, ranges)
+	slices.SortFunc(sorted, func(a, b Range) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	// Merge in place: sorted[:n] holds the ranges merged so far
+	n := 1
+	for _, r := range sorted[1:] {
+		last := &sorted[n-1]
+		if last.End == math.MaxInt64 || r.Start <= last.End+1 {
+			// Overlapping or adjacent - extend the last merged range
+			last.End = max(last.End, r.End)
+		} else {
+			sorted[n] = r
+			n++
+		}
+	}
+	return sorted[:n:n]
+}
+
+// Contains reports whether n lies in any merged range
+func (ix *RangeIndex) Contains(n int64) bool {
+	i := ix.search(n)
+	return i < len(ix.Ranges) && ix.Ranges[i].Start <= n
+}
+
+// search returns the index of the first merged range ending at or after n
+func (ix *RangeIndex) search(n int64) int {
+	return sort.Search(len(ix.Ranges), func(i int) bool {
+		return ix.Ranges[i].End >= n
+	})
+}
+
+// Total returns the number of distinct values covered by the merged ranges, or
+// math.MaxInt64 when that does not fit in int64 (see Overflows and TotalBig)
+func (ix *RangeIndex) Total() int64 {
+	if ix.overflow {
+		return math.MaxInt64
+	}
+	return ix.total
+}
+
+// Overflows reports whether the total is too large for int64
+func (ix *RangeIndex) Overflows() bool {
+	return ix.overflow
+}
+
+// TotalBig returns the exact number of distinct values covered by the merged ranges
+func (ix *RangeIndex) TotalBig() *big.Int {
+	if !ix.overflow {
+		return big.NewInt(ix.total)
+	}
+	total := new(big.Int)
+	for _, r := range ix.Ranges {
+		total.Add(total, NewBigRange(r).Size())
+	}
+	return total
+}
+
+// SourceIndex finds which of the original, unmerged ranges contain a number. The ranges
+// are sorted by start and read as an implicit balanced tree in which each node records
+// the largest end in its subtree, so a query skips every subtree ending before n.
+type SourceIndex struct {
+	ranges []Range // sorted by start
+	order  []int   // position of each sorted range in the input
+	maxEnd []int64 // largest end in the subtree rooted at each sorted position
+	source int     // number of input ranges the index was built from
+}
+
+// NewSourceIndex builds a SourceIndex over ranges; the input is not modified
+func NewSourceIndex(ranges []Range) *SourceIndex {
+	order := make([]int, len(ranges))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(ranges[a].Start, ranges[b].Start)
+	})
+
+	si := &SourceIndex{
+		ranges: make([]Range, len(ranges)),
+		order:  order,
+		maxEnd: make([]int64, len(ranges)),
+		source: len(ranges),
+	}
+	for i, pos := range order {
+		si.ranges[i] = ranges[pos]
+	}
+	si.build(0, len(ranges))
+	return si
+}
+
+// build fills maxEnd for the subtree over sorted positions [lo, hi) and returns its maximum
+func (si *SourceIndex) build(lo, hi int) int64 {
+	if lo >= hi {
+		return math.MinInt64
+	}
+	mid := (lo + hi) / 2
+	si.maxEnd[mid] = max(si.ranges[mid].End, si.build(lo, mid), si.build(mid+1, hi))
+	return si.maxEnd[mid]
+}
+
+// Containing returns the input positions of the ranges containing n, in input order
+func (si *SourceIndex) Containing(n int64) []int {
+	var positions []int
+	si.collect(0, len(si.ranges), n, &positions)
+	slices.Sort(positions)
+	return positions
+}
+
+func (si *SourceIndex) collect(lo, hi int, n int64, positions *[]int) {
+	if lo >= hi {
+		return
+	}
+	mid := (lo + hi) / 2
+	if si.maxEnd[mid] < n {
+		return
+	}
+	si.collect(lo, mid, n, positions)
+	if si.ranges[mid].Start > n {
+		// Everything to the right starts after n too
+		return
+	}
+	if si.ranges[mid].End >= n {
+		*positions = append(*positions, si.order[mid])
+	}
+	si.collect(mid+1, hi, n, positions)
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -18,14 +20,52 @@ func (r Range) Contains(n int64) bool {
 }
 
 type RangeList struct {
-	Ranges []Range
+	// Ranges are the input ranges in order. Add to them with AddRange; code that edits,
+	// reorders or replaces them directly must call Invalidate afterwards, or queries keep
+	// answering from the old ranges.
+	Ranges  []Range
+	index   *RangeIndex
+	sources *SourceIndex
 }
 
 func (rl *RangeList) AddRange(r Range) {
 	rl.Ranges = append(rl.Ranges, r)
+	rl.Invalidate()
+}
+
+// Invalidate drops the cached indexes so the next query rebuilds them from Ranges
+func (rl *RangeList) Invalidate() {
+	rl.index = nil
+	rl.sources = nil
+}
+
+// Index returns the merged interval index for the list, building it on first use and
+// rebuilding it after AddRange or Invalidate
+func (rl *RangeList) Index() *RangeIndex {
+	if rl.index == nil || rl.index.source != len(rl.Ranges) {
+		rl.index = NewRangeIndex(rl.Ranges)
+	}
+	return rl.index
 }
 
 func (rl *RangeList) IsValid(n int64) bool {
+	return rl.Index().Contains(n)
+}
+
+// RangesContaining returns the positions in Ranges of every range containing n
+func (rl *RangeList) RangesContaining(n int64) []int {
+	if rl.sources == nil || rl.sources.source != len(rl.Ranges) {
+		rl.sources = NewSourceIndex(rl.Ranges)
+	}
+	return rl.sources.Containing(n)
+}
+
+func (rl *RangeList) CountTotalValid() int64 {
+	return rl.Index().Total()
+}
+
+// isValidLinear checks every range in turn; it is the reference for the index benchmarks
+func (rl *RangeList) isValidLinear(n int64) bool {
 	for _, r := range rl.Ranges {
 		if r.Contains(n) {
 			return true
@@ -34,7 +74,9 @@ func (rl *RangeList) IsValid(n int64) bool {
 	return false
 }
 
-func (rl *RangeList) CountTotalValid() int64 {
+// countTotalValidQuicksort sorts and merges a copy of the ranges on every call; it is the
+// reference for the index benchmarks
+func (rl *RangeList) countTotalValidQuicksort() int64 {
 	if len(rl.Ranges) == 0 {
 		return 0
 	}
@@ -103,87 +145,243 @@ func (nl *NumberList) ValidateAgainstRanges(rangeList *RangeList) int64 {
 	var count int64 = 0
 	for _, num := range nl.Numbers {
 		valid := rangeList.IsValid(num)
-		fmt.Printf("%d: %t\n", num, valid)
 		if valid {
+			fmt.Printf("%d: %t (%s)\n", num, valid, describeMatches(rangeList, rangeList.RangesContaining(num)))
 			count++
+		} else {
+			fmt.Printf("%d: %t\n", num, valid)
 		}
 	}
 	return count
 }
 
-func parseRange(line string) (Range, error) {
-	parts := strings.Split(line, "-")
-	if len(parts) != 2 {
-		return Range{}, fmt.Errorf("invalid range format: %s", line)
+// describeMatches lists matching ranges by input line; ranges are the first lines of the
+// file, so the range at position i is on line i+1
+func describeMatches(rangeList *RangeList, positions []int) string {
+	parts := make([]string, len(positions))
+	for i, pos := range positions {
+		r := rangeList.Ranges[pos]
+		parts[i] = fmt.Sprintf("line %d: %d-%d", pos+1, r.Start, r.End)
 	}
-	start, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	return strings.Join(parts, ", ")
+}
+
+// parseRange parses any of the forms accepted by splitRange into an inclusive Range.
+// Open ends stand for the int64 limits.
+func parseRange(line string) (Range, error) {
+	b, err := splitRange(line)
 	if err != nil {
 		return Range{}, err
 	}
-	end, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+
+	r := Range{Start: math.MinInt64, End: math.MaxInt64}
+	if b.start != "" {
+		if r.Start, err = strconv.ParseInt(b.start, 10, 64); err != nil {
+			return Range{}, err
+		}
+	}
+	if b.end != "" {
+		if r.End, err = strconv.ParseInt(b.end, 10, 64); err != nil {
+			return Range{}, err
+		}
+	}
+
+	empty := fmt.Errorf("empty range: %s", line)
+	if b.startExclusive && b.start != "" {
+		if r.Start == math.MaxInt64 {
+			return Range{}, empty
+		}
+		r.Start++
+	}
+	if b.endExclusive && b.end != "" {
+		if r.End == math.MinInt64 {
+			return Range{}, empty
+		}
+		r.End--
+	}
+	if r.Start > r.End {
+		return Range{}, empty
+	}
+	return r, nil
+}
+
+// readInput reads the ranges section and the numbers section of a file
+func readInput(filePath string) (*RangeList, *NumberList, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return Range{}, err
+		return nil, nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	rangeList, err := readRanges(scanner)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	numberList := &NumberList{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		num, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing number '%s': %w", line, err)
+		}
+		numberList.AddNumber(num)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("reading file: %w", err)
 	}
-	return Range{Start: start, End: end}, nil
+	return rangeList, numberList, nil
+}
+
+// readRanges reads ranges up to the first blank line, leaving the scanner at the numbers
+func readRanges(scanner *bufio.Scanner) (*RangeList, error) {
+	rangeList := &RangeList{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			break
+		}
+		r, err := parseRange(line)
+		if err != nil {
+			return nil, fmt.Errorf("parsing range '%s': %w", line, err)
+		}
+		rangeList.AddRange(r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+	return rangeList, nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <path-to-file> <mode> [argument]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Modes:\n")
+	fmt.Fprintf(os.Stderr, "  validate                 - Count valid numbers from second list\n")
+	fmt.Fprintf(os.Stderr, "  total                    - Count total possible valid numbers from ranges\n")
+	fmt.Fprintf(os.Stderr, "  stream [numbers-file|-]  - Validate numbers as they are read, printing only a summary\n")
+	fmt.Fprintf(os.Stderr, "  session                  - Read add/remove/query/total/ranges commands from stdin\n")
+	fmt.Fprintf(os.Stderr, "  serve [address]          - Serve range queries over HTTP (default :8080)\n")
+	fmt.Fprintf(os.Stderr, "  union <other-file>       - Ranges covered by either file\n")
+	fmt.Fprintf(os.Stderr, "  intersect <other-file>   - Ranges covered by both files\n")
+	fmt.Fprintf(os.Stderr, "  difference <other-file>  - Ranges covered by the first file but not the other\n")
+	fmt.Fprintf(os.Stderr, "  complement <start-end>   - Parts of the bounding range not covered by the file\n")
 }
 
 func main() {
-	if len(os.Args) != 3 {
-		fmt.Fprintf(os.Stderr, "Usage: %s <path-to-file> <mode>\n", os.Args[0])
-		fmt.Fprintf(os.Stderr, "Modes:\n")
-		fmt.Fprintf(os.Stderr, "  validate - Count valid numbers from second list\n")
-		fmt.Fprintf(os.Stderr, "  total    - Count total possible valid numbers from ranges\n")
+	if len(os.Args) < 3 {
+		usage()
 		os.Exit(1)
 	}
 
 	filePath := os.Args[1]
 	mode := os.Args[2]
 	
-	if mode != "validate" && mode != "total" {
+	switch mode {
+	case "validate", "total":
+		if len(os.Args) != 3 {
+			usage()
+			os.Exit(1)
+		}
+	case "union", "intersect", "difference", "complement":
+		if len(os.Args) != 4 {
+			usage()
+			os.Exit(1)
+		}
+	case "session":
+		if len(os.Args) != 3 {
+			usage()
+			os.Exit(1)
+		}
+	case "serve":
+		if len(os.Args) > 4 {
+			usage()
+			os.Exit(1)
+		}
+		addr := ":8080"
+		if len(os.Args) == 4 {
+			addr = os.Args[3]
+		}
+		if err := runServer(filePath, addr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "stream":
+		if len(os.Args) > 4 {
+			usage()
+			os.Exit(1)
+		}
+		numbersPath := ""
+		if len(os.Args) == 4 {
+			numbersPath = os.Args[3]
+		}
+		if err := runStream(os.Stdout, filePath, numbersPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			os.Exit(1)
+		}
+		return
+	default:
 		fmt.Fprintf(os.Stderr, "Invalid mode: %s\n", mode)
-		fmt.Fprintf(os.Stderr, "Valid modes are: validate, total\n")
+		fmt.Fprintf(os.Stderr, "Valid modes are: validate, total, stream, session, serve, union, intersect, difference, complement\n")
+		os.Exit(1)
+	}
+
+	rangeList, numberList, err := readInput(filePath)
+	if errors.Is(err, strconv.ErrRange) && (mode == "validate" || mode == "total") {
+		// A bound or number does not fit in int64: switch to math/big
+		runBig(filePath, mode)
+		return
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		fmt.Fprintf(os.Stderr, "Error %v (this mode needs values that fit in int64)\n", err)
 		os.Exit(1)
 	}
-	file, err := os.Open(filePath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
 		os.Exit(1)
 	}
-	defer file.Close()
-
-	rangeList := &RangeList{}
-	numberList := &NumberList{}
-	
-	scanner := bufio.NewScanner(file)
-	parsingRanges := true
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		
-		if line == "" {
-			parsingRanges = false
-			continue
+	switch mode {
+	case "validate":
+		count := numberList.ValidateAgainstRanges(rangeList)
+		fmt.Printf("\nTotal valid numbers: %d\n", count)
+	case "total":
+		fmt.Println("Merged ranges:")
+		if err := writeRanges(os.Stdout, rangeList.Index().Ranges); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing ranges: %v\n", err)
+			os.Exit(1)
 		}
-
-		if parsingRanges {
-			r, err := parseRange(line)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error parsing range '%s': %v\n", line, err)
-				os.Exit(1)
-			}
-			rangeList.AddRange(r)
-		} else {
-			num, err := strconv.ParseInt(line, 10, 64)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error parsing number '%s': %v\n", line, err)
-				os.Exit(1)
-			}
-			numberList.AddNumber(num)
+		fmt.Println()
+		count := rangeList.Index().TotalBig()
+		fmt.Printf("Total possible valid numbers: %s\n", count)
+	case "session":
+		if err := runSession(os.Stdout, os.Stdin, NewDynamicRangeList(rangeList)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		result, err := rangeAlgebra(rangeList, mode, os.Args[3])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeRanges(os.Stdout, result.Ranges); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing ranges: %v\n", err)
+			os.Exit(1)
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+// runBig runs validate or total mode with math/big ranges and numbers
+func runBig(filePath, mode string) {
+	rangeList, numberList, err := readBigInput(filePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
 		os.Exit(1)
 	}
 
@@ -191,7 +389,15 @@ func main() {
 		count := numberList.ValidateAgainstRanges(rangeList)
 		fmt.Printf("\nTotal valid numbers: %d\n", count)
 	} else {
-		count := rangeList.CountTotalValid()
-		fmt.Printf("Total possible valid numbers: %d\n", count)
+		fmt.Println("Merged ranges:")
+		for _, r := range rangeList.Merged() {
+			fmt.Println(r)
+		}
+		fmt.Println()
+		if total := rangeList.CountTotalValid(); total != nil {
+			fmt.Printf("Total possible valid numbers: %s\n", total)
+		} else {
+			fmt.Println("Total possible valid numbers: infinite (a range is open-ended)")
+		}
 	}
 }
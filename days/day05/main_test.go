@@ -1,7 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestRangeContains(t *testing.T) {
@@ -259,3 +274,853 @@ for i := 0; i < b.N; i++ {
 rl.CountTotalValid()
 }
 }
+
+func TestRangeIndexMerged(t *testing.T) {
+	rl := &RangeList{}
+	rl.AddRange(Range{Start: 3, End: 5})
+	rl.AddRange(Range{Start: 10, End: 14})
+	rl.AddRange(Range{Start: 16, End: 20})
+	rl.AddRange(Range{Start: 12, End: 18})
+	rl.AddRange(Range{Start: 6, End: 7})
+
+	// 6-7 is adjacent to 3-5, 12-18 bridges 10-14 and 16-20
+	expected := []Range{{Start: 3, End: 7}, {Start: 10, End: 20}}
+	if !slices.Equal(rl.Index().Ranges, expected) {
+		t.Errorf("Index().Ranges = %+v, want %+v", rl.Index().Ranges, expected)
+	}
+
+	// Adding a range invalidates the cached index
+	rl.AddRange(Range{Start: 8, End: 9})
+	expected = []Range{{Start: 3, End: 20}}
+	if !slices.Equal(rl.Index().Ranges, expected) {
+		t.Errorf("Index().Ranges after AddRange = %+v, want %+v", rl.Index().Ranges, expected)
+	}
+	if rl.CountTotalValid() != 18 {
+		t.Errorf("Expected 18 total possible valid numbers, got %d", rl.CountTotalValid())
+	}
+
+	// Editing a range in place needs Invalidate, since the length is unchanged
+	rl.Ranges[0] = Range{Start: 100, End: 101}
+	rl.Invalidate()
+	if rl.IsValid(4) || !rl.IsValid(100) || !slices.Equal(rl.RangesContaining(101), []int{0}) {
+		t.Errorf("Queries after Invalidate still use the old ranges: %+v", rl.Index().Ranges)
+	}
+}
+
+func TestRangeIndexMatchesLinear(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for trial := 0; trial < 50; trial++ {
+		rl := &RangeList{}
+		for i := 0; i < 1+rng.Intn(40); i++ {
+			start := rng.Int63n(1000)
+			rl.AddRange(Range{Start: start, End: start + rng.Int63n(50)})
+		}
+
+		if got, want := rl.CountTotalValid(), rl.countTotalValidQuicksort(); got != want {
+			t.Fatalf("trial %d: CountTotalValid() = %d, quicksort path = %d", trial, got, want)
+		}
+		for n := int64(-1); n <= 1100; n++ {
+			if got, want := rl.IsValid(n), rl.isValidLinear(n); got != want {
+				t.Fatalf("trial %d: IsValid(%d) = %t, linear scan = %t", trial, n, got, want)
+			}
+		}
+	}
+}
+
+// benchmarkRanges returns 500 overlapping ranges in the hundred trillions and 1000 numbers
+// spread across them
+func benchmarkRanges() (*RangeList, []int64) {
+	rl := &RangeList{}
+	rng := rand.New(rand.NewSource(500))
+	for i := 0; i < 500; i++ {
+		start := 100000000000000 + rng.Int63n(500*2000000000000)
+		rl.AddRange(Range{Start: start, End: start + rng.Int63n(2000000000000)})
+	}
+	numbers := make([]int64, 1000)
+	for i := range numbers {
+		numbers[i] = 100000000000000 + rng.Int63n(500*2000000000000)
+	}
+	return rl, numbers
+}
+
+func BenchmarkValidateLinear500Ranges(b *testing.B) {
+	rl, numbers := benchmarkRanges()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, n := range numbers {
+			rl.isValidLinear(n)
+		}
+	}
+}
+
+func BenchmarkValidateIndexed500Ranges(b *testing.B) {
+	rl, numbers := benchmarkRanges()
+	rl.Index()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, n := range numbers {
+			rl.IsValid(n)
+		}
+	}
+}
+
+func BenchmarkCountTotalValidQuicksort500Ranges(b *testing.B) {
+	rl, _ := benchmarkRanges()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rl.countTotalValidQuicksort()
+	}
+}
+
+func TestRangeIndexSortedInput(t *testing.T) {
+	// Already sorted and reversed input, such as the output of the set operations, must
+	// not degrade the sort: the baseline quicksort took seconds on these
+	const count = 100000
+	sorted, reversed := &RangeList{}, &RangeList{}
+	for i := int64(0); i < count; i++ {
+		sorted.AddRange(Range{Start: i * 10, End: i*10 + 4})
+		reversed.AddRange(Range{Start: (count - 1 - i) * 10, End: (count-1-i)*10 + 4})
+	}
+
+	for name, rl := range map[string]*RangeList{"sorted": sorted, "reversed": reversed} {
+		if got := rl.CountTotalValid(); got != count*5 {
+			t.Errorf("%s: CountTotalValid() = %d, want %d", name, got, count*5)
+		}
+		if len(rl.Index().Ranges) != count {
+			t.Errorf("%s: expected %d disjoint ranges, got %d", name, count, len(rl.Index().Ranges))
+		}
+		if !rl.IsValid(999994) || rl.IsValid(999995) {
+			t.Errorf("%s: wrong membership at the last range", name)
+		}
+	}
+}
+
+func BenchmarkBuildIndexSortedRanges(b *testing.B) {
+	ranges := make([]Range, 100000)
+	for i := range ranges {
+		ranges[i] = Range{Start: int64(i) * 10, End: int64(i)*10 + 4}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewRangeIndex(ranges)
+	}
+}
+
+func BenchmarkBuildIndex500Ranges(b *testing.B) {
+	rl, _ := benchmarkRanges()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		NewRangeIndex(rl.Ranges)
+	}
+}
+
+func TestRangeAlgebra(t *testing.T) {
+	a := &RangeList{}
+	a.AddRange(Range{Start: 3, End: 5})
+	a.AddRange(Range{Start: 10, End: 14})
+	a.AddRange(Range{Start: 16, End: 20})
+	a.AddRange(Range{Start: 12, End: 18})
+
+	b := &RangeList{}
+	b.AddRange(Range{Start: 1, End: 4})
+	b.AddRange(Range{Start: 15, End: 30})
+
+	tests := []struct {
+		name     string
+		result   *RangeList
+		expected []Range
+	}{
+		{"union", a.Union(b), []Range{{1, 5}, {10, 30}}},
+		{"intersection", a.Intersection(b), []Range{{3, 4}, {15, 20}}},
+		{"difference", a.Difference(b), []Range{{5, 5}, {10, 14}}},
+		{"reverse difference", b.Difference(a), []Range{{1, 2}, {21, 30}}},
+		{"complement", a.Complement(Range{Start: 0, End: 25}), []Range{{0, 2}, {6, 9}, {21, 25}}},
+		{"complement inside", a.Complement(Range{Start: 11, End: 19}), nil},
+		{"complement of empty", (&RangeList{}).Complement(Range{Start: 1, End: 9}), []Range{{1, 9}}},
+	}
+
+	for _, tt := range tests {
+		if !slices.Equal(tt.result.Ranges, tt.expected) {
+			t.Errorf("%s = %+v, want %+v", tt.name, tt.result.Ranges, tt.expected)
+		}
+	}
+}
+
+func TestRangeAlgebraMatchesMembership(t *testing.T) {
+	rng := rand.New(rand.NewSource(34))
+	randomList := func() *RangeList {
+		rl := &RangeList{}
+		for i := 0; i < rng.Intn(15); i++ {
+			start := rng.Int63n(200)
+			rl.AddRange(Range{Start: start, End: start + rng.Int63n(30)})
+		}
+		return rl
+	}
+	bounds := Range{Start: 20, End: 180}
+
+	for trial := 0; trial < 100; trial++ {
+		a, b := randomList(), randomList()
+		union, inter, diff, comp := a.Union(b), a.Intersection(b), a.Difference(b), a.Complement(bounds)
+
+		for n := int64(-5); n <= 240; n++ {
+			inA, inB := a.IsValid(n), b.IsValid(n)
+			checks := []struct {
+				name     string
+				got      bool
+				expected bool
+			}{
+				{"union", union.IsValid(n), inA || inB},
+				{"intersection", inter.IsValid(n), inA && inB},
+				{"difference", diff.IsValid(n), inA && !inB},
+				{"complement", comp.IsValid(n), bounds.Contains(n) && !inA},
+			}
+			for _, c := range checks {
+				if c.got != c.expected {
+					t.Fatalf("trial %d: %s contains %d = %t, want %t", trial, c.name, n, c.got, c.expected)
+				}
+			}
+		}
+
+		// Results are already merged
+		for _, result := range []*RangeList{union, inter, diff, comp} {
+			if !slices.Equal(result.Ranges, result.Index().Ranges) {
+				t.Fatalf("trial %d: result %+v is not merged", trial, result.Ranges)
+			}
+		}
+	}
+}
+
+func TestRangesContaining(t *testing.T) {
+	rl := &RangeList{}
+	rl.AddRange(Range{Start: 3, End: 5})
+	rl.AddRange(Range{Start: 10, End: 14})
+	rl.AddRange(Range{Start: 16, End: 20})
+	rl.AddRange(Range{Start: 12, End: 18})
+
+	tests := []struct {
+		num      int64
+		expected []int
+	}{
+		{1, nil},
+		{5, []int{0}},
+		{11, []int{1}},
+		{13, []int{1, 3}},
+		{15, []int{3}},
+		{17, []int{2, 3}},
+		{32, nil},
+	}
+
+	for _, tt := range tests {
+		if result := rl.RangesContaining(tt.num); !slices.Equal(result, tt.expected) {
+			t.Errorf("RangesContaining(%d) = %v, want %v", tt.num, result, tt.expected)
+		}
+	}
+
+	// Random ranges against a linear scan
+	rng := rand.New(rand.NewSource(35))
+	rl = &RangeList{}
+	for i := 0; i < 200; i++ {
+		start := rng.Int63n(1000)
+		rl.AddRange(Range{Start: start, End: start + rng.Int63n(100)})
+	}
+	for n := int64(-1); n <= 1100; n++ {
+		var expected []int
+		for i, r := range rl.Ranges {
+			if r.Contains(n) {
+				expected = append(expected, i)
+			}
+		}
+		if result := rl.RangesContaining(n); !slices.Equal(result, expected) {
+			t.Fatalf("RangesContaining(%d) = %v, want %v", n, result, expected)
+		}
+	}
+}
+
+func TestCountTotalValidOverflow(t *testing.T) {
+	rl := &RangeList{}
+	rl.AddRange(Range{Start: 0, End: math.MaxInt64})
+
+	ix := rl.Index()
+	if !ix.Overflows() {
+		t.Fatalf("Expected 0-MaxInt64 to overflow int64")
+	}
+	if rl.CountTotalValid() != math.MaxInt64 {
+		t.Errorf("CountTotalValid() = %d, want saturated %d", rl.CountTotalValid(), int64(math.MaxInt64))
+	}
+	expected, _ := new(big.Int).SetString("9223372036854775808", 10)
+	if ix.TotalBig().Cmp(expected) != 0 {
+		t.Errorf("TotalBig() = %s, want %s", ix.TotalBig(), expected)
+	}
+
+	// Two ranges that fit individually but not together
+	rl = &RangeList{}
+	rl.AddRange(Range{Start: -10, End: math.MaxInt64 / 2})
+	rl.AddRange(Range{Start: math.MaxInt64/2 + 2, End: math.MaxInt64})
+	expected = new(big.Int).SetInt64(math.MaxInt64)
+	expected.Add(expected, big.NewInt(10))
+	if !rl.Index().Overflows() {
+		t.Errorf("Expected the sum of two ranges to overflow int64")
+	}
+	if rl.Index().TotalBig().Cmp(expected) != 0 {
+		t.Errorf("TotalBig() = %s, want %s", rl.Index().TotalBig(), expected)
+	}
+}
+
+func TestBigRangeList(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+	}{
+		{"18446744073709551610-18446744073709551620", false},
+		{"5-10", false},
+		{"9223372036854775800-18446744073709551611", false},
+		{"1-x", true},
+		{"1-2-3", true},
+	}
+
+	bl := &BigRangeList{}
+	for _, tt := range tests {
+		r, err := parseBigRange(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("parseBigRange(%q) expected error, got nil", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseBigRange(%q) unexpected error: %v", tt.input, err)
+		}
+		bl.AddRange(r)
+	}
+
+	if merged := bl.Merged(); len(merged) != 2 || merged[1].String() != "9223372036854775800-18446744073709551620" {
+		t.Errorf("Merged() = %v, want [5-10 9223372036854775800-18446744073709551620]", merged)
+	}
+	if total := bl.CountTotalValid().String(); total != "9223372036854775827" {
+		t.Errorf("CountTotalValid() = %s, want 9223372036854775827", total)
+	}
+
+	valid := []string{"5", "10", "9223372036854775800", "18446744073709551615", "18446744073709551620"}
+	invalid := []string{"4", "11", "9223372036854775799", "18446744073709551621", "99999999999999999999999"}
+	for _, s := range valid {
+		if n, _ := parseBigInt(s); !bl.IsValid(n) {
+			t.Errorf("Expected %s to be valid", s)
+		}
+	}
+	for _, s := range invalid {
+		if n, _ := parseBigInt(s); bl.IsValid(n) {
+			t.Errorf("Expected %s to NOT be valid", s)
+		}
+	}
+
+	n, _ := parseBigInt("18446744073709551611")
+	if positions := bl.RangesContaining(n); !slices.Equal(positions, []int{0, 2}) {
+		t.Errorf("RangesContaining(%s) = %v, want [0 2]", n, positions)
+	}
+}
+
+func TestBigRangeListOpenEnds(t *testing.T) {
+	bl := &BigRangeList{}
+	for _, line := range []string{"5-", "-2-3", "..-10", "(1,5)"} {
+		r, err := parseBigRange(line)
+		if err != nil {
+			t.Fatalf("parseBigRange(%q) unexpected error: %v", line, err)
+		}
+		bl.AddRange(r)
+	}
+
+	// ..-10 stays apart; -2-3, [2,4] and 5.. merge into -2..
+	merged := bl.Merged()
+	if len(merged) != 2 || merged[0].String() != "..-10" || merged[1].String() != "-2.." {
+		t.Errorf("Merged() = %v, want [..-10 -2..]", merged)
+	}
+	if total := bl.CountTotalValid(); total != nil {
+		t.Errorf("CountTotalValid() = %s, want nil for unbounded ranges", total)
+	}
+
+	valid := []string{"5", "9223372036854775808", "99999999999999999999999", "-10", "-99999999999999999999999", "-2", "4"}
+	invalid := []string{"-9", "-3"}
+	for _, s := range valid {
+		if n, _ := parseBigInt(s); !bl.IsValid(n) {
+			t.Errorf("Expected %s to be valid", s)
+		}
+	}
+	for _, s := range invalid {
+		if n, _ := parseBigInt(s); bl.IsValid(n) {
+			t.Errorf("Expected %s to NOT be valid", s)
+		}
+	}
+
+	n, _ := parseBigInt("99999999999999999999999")
+	if positions := bl.RangesContaining(n); !slices.Equal(positions, []int{0}) {
+		t.Errorf("RangesContaining(%s) = %v, want [0]", n, positions)
+	}
+}
+
+func TestBigRangeListMatchesRangeList(t *testing.T) {
+	rng := rand.New(rand.NewSource(36))
+	rl := &RangeList{}
+	for i := 0; i < 100; i++ {
+		start := rng.Int63n(1000)
+		rl.AddRange(Range{Start: start, End: start + rng.Int63n(40)})
+	}
+	bl := NewBigRangeList(rl)
+
+	if bl.CountTotalValid().Int64() != rl.CountTotalValid() {
+		t.Errorf("BigRangeList total = %s, RangeList total = %d", bl.CountTotalValid(), rl.CountTotalValid())
+	}
+	for n := int64(-1); n <= 1100; n++ {
+		if bl.IsValid(big.NewInt(n)) != rl.IsValid(n) {
+			t.Fatalf("IsValid(%d) differs between BigRangeList and RangeList", n)
+		}
+	}
+}
+
+func TestReadInputFallsBackToBig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.txt")
+	data := "5-10\n18446744073709551610-18446744073709551620\n\n7\n18446744073709551615\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := readInput(path); !errors.Is(err, strconv.ErrRange) {
+		t.Fatalf("readInput() error = %v, want strconv.ErrRange", err)
+	}
+	bl, nl, err := readBigInput(path)
+	if err != nil {
+		t.Fatalf("readBigInput() unexpected error: %v", err)
+	}
+	if len(bl.Ranges) != 2 || len(nl.Numbers) != 2 {
+		t.Fatalf("readBigInput() read %d ranges and %d numbers, want 2 and 2", len(bl.Ranges), len(nl.Numbers))
+	}
+	if count := nl.ValidateAgainstRanges(bl); count != 2 {
+		t.Errorf("Expected 2 valid numbers, got %d", count)
+	}
+}
+
+func TestStreamValidate(t *testing.T) {
+	rl := &RangeList{}
+	rl.AddRange(Range{Start: 3, End: 5})
+	rl.AddRange(Range{Start: 10, End: 14})
+	rl.AddRange(Range{Start: 16, End: 20})
+	rl.AddRange(Range{Start: 12, End: 18})
+
+	tests := []struct {
+		input       string
+		expected    StreamSummary
+		expectError bool
+	}{
+		{"1\n5\n8\n11\n17\n32\n", StreamSummary{Checked: 6, Valid: 3}, false},
+		{"\n15\n\n99999999999999999999\n", StreamSummary{Checked: 2, Valid: 1}, false},
+		{"", StreamSummary{}, false},
+		{"4\nabc\n", StreamSummary{}, true},
+	}
+
+	for _, tt := range tests {
+		summary, err := StreamValidate(bufio.NewScanner(strings.NewReader(tt.input)), rl.Check())
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("StreamValidate(%q) expected error, got nil", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("StreamValidate(%q) unexpected error: %v", tt.input, err)
+		}
+		if summary != tt.expected {
+			t.Errorf("StreamValidate(%q) = %+v, want %+v", tt.input, summary, tt.expected)
+		}
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	dir := t.TempDir()
+	numbers := filepath.Join(dir, "numbers.txt")
+	if err := os.WriteFile(numbers, []byte("3\n12\n21\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bigFile := filepath.Join(dir, "big.txt")
+	if err := os.WriteFile(bigFile, []byte("5-10\n18446744073709551610-18446744073709551620\n\n7\n18446744073709551615\n99999999999999999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     string
+		numbers  string
+		expected string
+	}{
+		{"example-data.txt", "", "Numbers checked: 6\nInvalid numbers: 3\n\nTotal valid numbers: 3\n"},
+		{"example-data.txt", numbers, "Numbers checked: 3\nInvalid numbers: 1\n\nTotal valid numbers: 2\n"},
+		{bigFile, "", "Numbers checked: 3\nInvalid numbers: 1\n\nTotal valid numbers: 2\n"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		if err := runStream(&out, tt.file, tt.numbers); err != nil {
+			t.Fatalf("runStream(%s, %q) unexpected error: %v", tt.file, tt.numbers, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("runStream(%s, %q) = %q, want %q", tt.file, tt.numbers, out.String(), tt.expected)
+		}
+	}
+}
+
+func TestDynamicRangeListMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(38))
+	dl := &DynamicRangeList{}
+	var covered [300]bool
+
+	for step := 0; step < 3000; step++ {
+		start := rng.Int63n(280)
+		r := Range{Start: start, End: start + rng.Int63n(20)}
+		if rng.Intn(3) == 0 {
+			dl.RemoveRange(r)
+		} else {
+			dl.AddRange(r)
+		}
+		for n := r.Start; n <= r.End; n++ {
+			covered[n] = dl.IsValid(n)
+		}
+
+		var expectedTotal int64
+		var expectedRanges []Range
+		for n, c := range covered {
+			if !c {
+				continue
+			}
+			expectedTotal++
+			if last := len(expectedRanges) - 1; last >= 0 && expectedRanges[last].End == int64(n)-1 {
+				expectedRanges[last].End++
+			} else {
+				expectedRanges = append(expectedRanges, Range{Start: int64(n), End: int64(n)})
+			}
+		}
+
+		if dl.CountTotalValid() != expectedTotal {
+			t.Fatalf("step %d: CountTotalValid() = %d, want %d", step, dl.CountTotalValid(), expectedTotal)
+		}
+		if !slices.Equal(dl.Ranges(), expectedRanges) || dl.Len() != len(expectedRanges) {
+			t.Fatalf("step %d: Ranges() = %+v, want %+v", step, dl.Ranges(), expectedRanges)
+		}
+	}
+}
+
+func TestDynamicRangeListAddRemove(t *testing.T) {
+	rl := &RangeList{}
+	rl.AddRange(Range{Start: 3, End: 5})
+	rl.AddRange(Range{Start: 10, End: 14})
+	rl.AddRange(Range{Start: 16, End: 20})
+	rl.AddRange(Range{Start: 12, End: 18})
+	dl := NewDynamicRangeList(rl)
+
+	steps := []struct {
+		add      bool
+		r        Range
+		expected []Range
+		total    int64
+	}{
+		{false, Range{Start: 12, End: 17}, []Range{{3, 5}, {10, 11}, {18, 20}}, 8},
+		{true, Range{Start: 6, End: 9}, []Range{{3, 11}, {18, 20}}, 12},
+		{false, Range{Start: 0, End: 3}, []Range{{4, 11}, {18, 20}}, 11},
+		{false, Range{Start: 21, End: 30}, []Range{{4, 11}, {18, 20}}, 11},
+		{true, Range{Start: 12, End: 17}, []Range{{4, 20}}, 17},
+		{false, Range{Start: 4, End: 20}, []Range{}, 0},
+	}
+
+	for i, s := range steps {
+		if s.add {
+			dl.AddRange(s.r)
+		} else {
+			dl.RemoveRange(s.r)
+		}
+		if !slices.Equal(dl.Ranges(), s.expected) || dl.CountTotalValid() != s.total {
+			t.Errorf("step %d: Ranges() = %+v total %d, want %+v total %d", i, dl.Ranges(), dl.CountTotalValid(), s.expected, s.total)
+		}
+	}
+}
+
+func TestDynamicRangeListExtremes(t *testing.T) {
+	dl := &DynamicRangeList{}
+	dl.AddRange(Range{Start: math.MinInt64, End: -1})
+	dl.AddRange(Range{Start: 0, End: math.MaxInt64})
+
+	if dl.Len() != 1 || !dl.IsValid(math.MinInt64) || !dl.IsValid(math.MaxInt64) {
+		t.Fatalf("Expected one range covering every int64, got %+v", dl.Ranges())
+	}
+	if total := dl.TotalBig().String(); total != "18446744073709551616" {
+		t.Errorf("TotalBig() = %s, want 18446744073709551616", total)
+	}
+	if dl.CountTotalValid() != math.MaxInt64 {
+		t.Errorf("CountTotalValid() = %d, want saturated %d", dl.CountTotalValid(), int64(math.MaxInt64))
+	}
+
+	dl.RemoveRange(Range{Start: math.MaxInt64, End: math.MaxInt64})
+	dl.RemoveRange(Range{Start: math.MinInt64, End: math.MinInt64 + 1})
+	if total := dl.TotalBig().String(); total != "18446744073709551613" {
+		t.Errorf("TotalBig() after removals = %s, want 18446744073709551613", total)
+	}
+	expected := []Range{{math.MinInt64 + 2, math.MaxInt64 - 1}}
+	if !slices.Equal(dl.Ranges(), expected) {
+		t.Errorf("Ranges() = %+v, want %+v", dl.Ranges(), expected)
+	}
+}
+
+func TestRunSession(t *testing.T) {
+	rl, _, err := readInput("example-data.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := "total\nremove 12-17\nquery 15\nquery 18\nadd 6-9\nranges\ntotal\n"
+	expected := "Total possible valid numbers: 14\n15: false\n18: true\n3-11\n18-20\nTotal possible valid numbers: 12\n"
+	var out strings.Builder
+	if err := runSession(&out, strings.NewReader(input), NewDynamicRangeList(rl)); err != nil {
+		t.Fatalf("runSession() unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("runSession() = %q, want %q", out.String(), expected)
+	}
+
+	for _, bad := range []string{"add\n", "query x\n", "total 5\n", "jump 5\n"} {
+		if err := runSession(io.Discard, strings.NewReader(bad), &DynamicRangeList{}); err == nil {
+			t.Errorf("runSession(%q) expected error, got nil", bad)
+		}
+	}
+}
+
+func BenchmarkDynamicRangeListAddRemove(b *testing.B) {
+	rng := rand.New(rand.NewSource(38))
+	dl := &DynamicRangeList{}
+	for i := 0; i < 10000; i++ {
+		start := rng.Int63n(1000000000000)
+		dl.AddRange(Range{Start: start, End: start + rng.Int63n(50000000)})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := rng.Int63n(1000000000000)
+		r := Range{Start: start, End: start + rng.Int63n(50000000)}
+		if i%2 == 0 {
+			dl.AddRange(r)
+		} else {
+			dl.RemoveRange(r)
+		}
+	}
+}
+
+func TestRangeServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges.txt")
+	if err := os.WriteFile(path, []byte("3-5\n10-14\n16-20\n12-18\n\n1\n5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRangeServer(path)
+	if err != nil {
+		t.Fatalf("NewRangeServer() unexpected error: %v", err)
+	}
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	tests := []struct {
+		method   string
+		path     string
+		body     string
+		status   int
+		expected string
+	}{
+		{"GET", "/valid?n=17", "", http.StatusOK, `{"n":17,"valid":true,"lines":[3,4]}`},
+		{"GET", "/valid?n=8", "", http.StatusOK, `{"n":8,"valid":false}`},
+		{"GET", "/valid?n=abc", "", http.StatusBadRequest, ""},
+		{"GET", "/valid", "", http.StatusBadRequest, ""},
+		{"POST", "/validate", "[1, 5, 13]", http.StatusOK,
+			`{"checked":3,"valid":2,"results":[{"n":1,"valid":false},{"n":5,"valid":true,"lines":[1]},{"n":13,"valid":true,"lines":[2,4]}]}`},
+		{"POST", "/validate", `["x"]`, http.StatusBadRequest, ""},
+		{"POST", "/validate", "[" + strings.Repeat("1,", maxValidateBody/2) + "1]", http.StatusRequestEntityTooLarge, ""},
+		{"GET", "/validate", "", http.StatusMethodNotAllowed, ""},
+		{"GET", "/total", "", http.StatusOK, `{"ranges":["3-5","10-20"],"total":14}`},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tt.method, tt.path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, resp.StatusCode, tt.status)
+		}
+		if tt.expected != "" && strings.TrimSpace(string(body)) != tt.expected {
+			t.Errorf("%s %s = %s, want %s", tt.method, tt.path, body, tt.expected)
+		}
+	}
+
+	hs := s.HTTPServer(":0")
+	if hs.ReadHeaderTimeout == 0 || hs.ReadTimeout == 0 || hs.WriteTimeout == 0 || hs.IdleTimeout == 0 {
+		t.Errorf("HTTPServer() leaves a timeout unset: %+v", hs)
+	}
+}
+
+func TestRangeServerReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges.txt")
+	if err := os.WriteFile(path, []byte("3-5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRangeServer(path)
+	if err != nil {
+		t.Fatalf("NewRangeServer() unexpected error: %v", err)
+	}
+
+	get := func(target string) string {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return strings.TrimSpace(rec.Body.String())
+	}
+
+	if reloaded, err := s.ReloadIfChanged(); reloaded || err != nil {
+		t.Errorf("ReloadIfChanged() on an unchanged file = %t, %v; want false, nil", reloaded, err)
+	}
+
+	// Rewrite the file with a different time so the change is seen whatever the
+	// file system's time resolution
+	if err := os.WriteFile(path, []byte("3-5\n10-14\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := s.ReloadIfChanged(); !reloaded || err != nil {
+		t.Fatalf("ReloadIfChanged() after a change = %t, %v; want true, nil", reloaded, err)
+	}
+	if got := get("/valid?n=12"); got != `{"n":12,"valid":true,"lines":[2]}` {
+		t.Errorf("/valid?n=12 after reload = %s", got)
+	}
+
+	// A broken file keeps the previous ranges
+	if err := os.WriteFile(path, []byte("3-x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ReloadIfChanged(); err == nil {
+		t.Errorf("ReloadIfChanged() with a bad range expected error, got nil")
+	}
+	if got := get("/total"); got != `{"ranges":["3-5","10-14"],"total":8}` {
+		t.Errorf("/total after a failed reload = %s", got)
+	}
+
+	// Watch picks up the next change on its own
+	if err := os.WriteFile(path, []byte("1-100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx, 10*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for get("/total") != `{"ranges":["1-100"],"total":100}` {
+		if time.Now().After(deadline) {
+			t.Fatalf("Watch did not reload the file, /total = %s", get("/total"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseRangeSyntax(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Range
+		expectError bool
+	}{
+		{"-5--1", Range{Start: -5, End: -1}, false},
+		{"-5-10", Range{Start: -5, End: 10}, false},
+		{"10 - 14", Range{Start: 10, End: 14}, false},
+		{"3..5", Range{Start: 3, End: 5}, false},
+		{"-10..-3", Range{Start: -10, End: -3}, false},
+		{"[3,6)", Range{Start: 3, End: 5}, false},
+		{"(2,5]", Range{Start: 3, End: 5}, false},
+		{"(2, 6)", Range{Start: 3, End: 5}, false},
+		{"[-3,-1]", Range{Start: -3, End: -1}, false},
+		{"100-", Range{Start: 100, End: math.MaxInt64}, false},
+		{"-5-", Range{Start: -5, End: math.MaxInt64}, false},
+		{"100..", Range{Start: 100, End: math.MaxInt64}, false},
+		{"-20", Range{Start: math.MinInt64, End: 20}, false},
+		{"--20", Range{Start: math.MinInt64, End: -20}, false},
+		{"..20", Range{Start: math.MinInt64, End: 20}, false},
+		{"[5,)", Range{Start: 5, End: math.MaxInt64}, false},
+		{"[5,5]", Range{Start: 5, End: 5}, false},
+		{"[5,5)", Range{}, true},
+		{"5-3", Range{}, true},
+		{"-", Range{}, true},
+		{"..", Range{}, true},
+		{"1..2..3", Range{}, true},
+		{"[1,2", Range{}, true},
+		{"[1,2,3]", Range{}, true},
+		{"a..b", Range{}, true},
+	}
+
+	for _, tt := range tests {
+		result, err := parseRange(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("parseRange(%q) = %+v, expected error", tt.input, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRange(%q) unexpected error: %v", tt.input, err)
+		} else if result != tt.expected {
+			t.Errorf("parseRange(%q) = %+v, want %+v", tt.input, result, tt.expected)
+		}
+
+		// The math/big parser agrees, leaving open ends unbounded instead of at the
+		// int64 limits
+		bigResult, err := parseBigRange(tt.input)
+		if err != nil {
+			t.Errorf("parseBigRange(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		startOK := bigResult.Start == nil && tt.expected.Start == math.MinInt64 ||
+			bigResult.Start != nil && bigResult.Start.Int64() == tt.expected.Start
+		endOK := bigResult.End == nil && tt.expected.End == math.MaxInt64 ||
+			bigResult.End != nil && bigResult.End.Int64() == tt.expected.End
+		if !startOK || !endOK {
+			t.Errorf("parseBigRange(%q) = %s, want %+v", tt.input, bigResult, tt.expected)
+		}
+	}
+}
+
+func TestMixedRangeSyntax(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mixed.txt")
+	data := "-10--5\n[-6,0)\n3..5\n(9,14]\n12-18\n[16,21)\n\n-11\n-7\n0\n-1\n17\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rl, nl, err := readInput(path)
+	if err != nil {
+		t.Fatalf("readInput() unexpected error: %v", err)
+	}
+
+	// -10..-1, 3..5 and 10..20 once merged
+	expected := []Range{{-10, -1}, {3, 5}, {10, 20}}
+	if !slices.Equal(rl.Index().Ranges, expected) {
+		t.Errorf("merged ranges = %+v, want %+v", rl.Index().Ranges, expected)
+	}
+	if rl.CountTotalValid() != 24 {
+		t.Errorf("Expected 24 total possible valid numbers, got %d", rl.CountTotalValid())
+	}
+	if count := nl.ValidateAgainstRanges(rl); count != 3 {
+		t.Errorf("Expected 3 valid numbers, got %d", count)
+	}
+	if positions := rl.RangesContaining(-6); !slices.Equal(positions, []int{0, 1}) {
+		t.Errorf("RangesContaining(-6) = %v, want [0 1]", positions)
+	}
+}
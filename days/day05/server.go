@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxValidateBody limits the size of a POST /validate body, about 500,000 numbers
+const maxValidateBody = 10 << 20
+
+// Timeouts for the long-running server, so slow or idle clients cannot hold connections
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+// RangeServer answers range queries over HTTP for a ranges file, reloading the file when
+// it changes. Each load is a complete RangeList that is only read once published, so
+// requests never see a half-loaded file.
+type RangeServer struct {
+	path   string
+	ranges atomic.Pointer[RangeList]
+
+	mu      sync.Mutex // serializes reloads
+	modTime time.Time
+	size    int64
+}
+
+// NewRangeServer loads the ranges section of path
+func NewRangeServer(path string) (*RangeServer, error) {
+	s := &RangeServer{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload rereads the ranges file; on error the previous ranges stay in use
+func (s *RangeServer) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reload()
+}
+
+func (s *RangeServer) reload() error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+	rangeList, err := readRanges(bufio.NewScanner(file))
+	if err != nil {
+		return err
+	}
+
+	// Build both indexes now, since lookups from concurrent requests must not build them
+	rangeList.Index()
+	rangeList.RangesContaining(0)
+
+	s.ranges.Store(rangeList)
+	s.modTime, s.size = info.ModTime(), info.Size()
+	return nil
+}
+
+// ReloadIfChanged reloads the ranges file if its modification time or size has changed
+// since the last load, reporting whether it did
+func (s *RangeServer) ReloadIfChanged() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return false, fmt.Errorf("reading file: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return false, nil
+	}
+	if err := s.reload(); err != nil {
+		// Remember the broken version so it is reported once, not on every check
+		s.modTime, s.size = info.ModTime(), info.Size()
+		return false, err
+	}
+	return true, nil
+}
+
+// Watch checks the ranges file for changes every interval until ctx is done
+func (s *RangeServer) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := s.ReloadIfChanged()
+			switch {
+			case err != nil:
+				log.Printf("Error reloading %s (keeping previous ranges): %v", s.path, err)
+			case reloaded:
+				log.Printf("Reloaded %s: %d ranges", s.path, len(s.ranges.Load().Ranges))
+			}
+		}
+	}
+}
+
+// Handler returns the HTTP routes:
+//
+//	GET  /valid?n=<n>  whether n is valid and the input lines of the ranges containing it
+//	POST /validate     a JSON array of numbers, validated together
+//	GET  /total        the merged ranges and the total number of valid numbers
+func (s *RangeServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /valid", s.handleValid)
+	mux.HandleFunc("POST /validate", s.handleValidate)
+	mux.HandleFunc("GET /total", s.handleTotal)
+	return mux
+}
+
+type validResponse struct {
+	N     int64 `json:"n"`
+	Valid bool  `json:"valid"`
+	Lines []int `json:"lines,omitempty"`
+}
+
+type validateResponse struct {
+	Checked int             `json:"checked"`
+	Valid   int             `json:"valid"`
+	Results []validResponse `json:"results"`
+}
+
+type totalResponse struct {
+	Ranges []string `json:"ranges"`
+	Total  *big.Int `json:"total"`
+}
+
+func (s *RangeServer) handleValid(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.ParseInt(r.URL.Query().Get("n"), 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid number '%s'", r.URL.Query().Get("n")), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, checkNumber(s.ranges.Load(), n))
+}
+
+func (s *RangeServer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	var numbers []int64
+	body := http.MaxBytesReader(w, r.Body, maxValidateBody)
+	if err := json.NewDecoder(body).Decode(&numbers); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body is larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("expected a JSON array of integers: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// Use one snapshot for the whole batch, even if the file is reloaded meanwhile
+	rangeList := s.ranges.Load()
+	response := validateResponse{Checked: len(numbers), Results: make([]validResponse, len(numbers))}
+	for i, n := range numbers {
+		response.Results[i] = checkNumber(rangeList, n)
+		if response.Results[i].Valid {
+			response.Valid++
+		}
+	}
+	writeJSON(w, response)
+}
+
+func (s *RangeServer) handleTotal(w http.ResponseWriter, r *http.Request) {
+	index := s.ranges.Load().Index()
+	response := totalResponse{Ranges: make([]string, len(index.Ranges)), Total: index.TotalBig()}
+	for i, rg := range index.Ranges {
+		response.Ranges[i] = fmt.Sprintf("%d-%d", rg.Start, rg.End)
+	}
+	writeJSON(w, response)
+}
+
+// checkNumber validates n, listing matching ranges by input line (position + 1)
+func checkNumber(rangeList *RangeList, n int64) validResponse {
+	response := validResponse{N: n, Valid: rangeList.IsValid(n)}
+	for _, pos := range rangeList.RangesContaining(n) {
+		response.Lines = append(response.Lines, pos+1)
+	}
+	return response
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// HTTPServer returns a server for Handler on addr with read, write and idle timeouts set
+func (s *RangeServer) HTTPServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+}
+
+// runServer serves the ranges of filePath on addr, checking the file for changes every second
+func runServer(filePath, addr string) error {
+	s, err := NewRangeServer(filePath)
+	if err != nil {
+		return err
+	}
+	go s.Watch(context.Background(), time.Second)
+
+	log.Printf("Serving %s (%d ranges) on %s", filePath, len(s.ranges.Load().Ranges), addr)
+	return s.HTTPServer(addr).ListenAndServe()
+}
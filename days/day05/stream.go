@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// StreamSummary counts the results of a streaming validation
+type StreamSummary struct {
+	Checked int64
+	Valid   int64
+}
+
+// NumberCheck parses one number and reports whether it is valid
+type NumberCheck func(s string) (bool, error)
+
+// StreamValidate checks each number as it is read from the scanner, keeping only the counts
+func StreamValidate(scanner *bufio.Scanner, check NumberCheck) (StreamSummary, error) {
+	var summary StreamSummary
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		valid, err := check(line)
+		if err != nil {
+			return summary, fmt.Errorf("parsing number '%s': %w", line, err)
+		}
+		summary.Checked++
+		if valid {
+			summary.Valid++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("reading numbers: %w", err)
+	}
+	return summary, nil
+}
+
+// Check returns a NumberCheck against the list. Numbers beyond int64 cannot fall in any
+// of its ranges, so they count as invalid rather than failing.
+func (rl *RangeList) Check() NumberCheck {
+	rl.Index()
+	return func(s string) (bool, error) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if errors.Is(err, strconv.ErrRange) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return rl.IsValid(n), nil
+	}
+}
+
+// Check returns a NumberCheck against the list
+func (bl *BigRangeList) Check() NumberCheck {
+	bl.Merged()
+	return func(s string) (bool, error) {
+		n, err := parseBigInt(s)
+		if err != nil {
+			return false, err
+		}
+		return bl.IsValid(n), nil
+	}
+}
+
+// runStream validates numbers as they are read, either from the rest of the ranges file or
+// from numbersPath ("-" for standard input), and prints a summary
+func runStream(w io.Writer, filePath, numbersPath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	var check NumberCheck
+	rangeList, err := readRanges(scanner)
+	switch {
+	case errors.Is(err, strconv.ErrRange):
+		// A bound does not fit in int64: reread the ranges with math/big
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("reading file: %w", err)
+		}
+		scanner = bufio.NewScanner(file)
+		bigList, err := readBigRanges(scanner)
+		if err != nil {
+			return err
+		}
+		check = bigList.Check()
+	case err != nil:
+		return err
+	default:
+		check = rangeList.Check()
+	}
+
+	switch numbersPath {
+	case "":
+	case "-":
+		scanner = bufio.NewScanner(os.Stdin)
+	default:
+		numbers, err := os.Open(numbersPath)
+		if err != nil {
+			return fmt.Errorf("opening numbers file: %w", err)
+		}
+		defer numbers.Close()
+		scanner = bufio.NewScanner(numbers)
+	}
+
+	summary, err := StreamValidate(scanner, check)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Numbers checked: %d\n", summary.Checked)
+	fmt.Fprintf(w, "Invalid numbers: %d\n", summary.Checked-summary.Valid)
+	fmt.Fprintf(w, "\nTotal valid numbers: %d\n", summary.Valid)
+	return nil
+}
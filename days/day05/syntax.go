@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rangeBounds is a range split into the text of its bounds. An empty bound is
+// open-ended; an exclusive bound leaves out the value itself.
+type rangeBounds struct {
+	start, end                   string
+	startExclusive, endExclusive bool
+}
+
+// splitRange splits a range written in any of the supported forms:
+//
+//	a-b     inclusive, either bound may be negative (-5--1)
+//	a..b    inclusive
+//	[a,b)   interval notation; '[' and ']' include a bound, '(' and ')' exclude it
+//	a- a..  open-ended above
+//	-b ..b  open-ended below (--5 ends at -5)
+func splitRange(line string) (rangeBounds, error) {
+	s := strings.TrimSpace(line)
+	var b rangeBounds
+
+	switch {
+	case strings.HasPrefix(s, "[") || strings.HasPrefix(s, "("):
+		if !strings.HasSuffix(s, "]") && !strings.HasSuffix(s, ")") {
+			return b, fmt.Errorf("invalid range format: %s", line)
+		}
+		parts := strings.Split(s[1:len(s)-1], ",")
+		if len(parts) != 2 {
+			return b, fmt.Errorf("invalid range format: %s", line)
+		}
+		b.start, b.end = parts[0], parts[1]
+		b.startExclusive = s[0] == '('
+		b.endExclusive = s[len(s)-1] == ')'
+
+	case strings.Contains(s, ".."):
+		parts := strings.Split(s, "..")
+		if len(parts) != 2 {
+			return b, fmt.Errorf("invalid range format: %s", line)
+		}
+		b.start, b.end = parts[0], parts[1]
+
+	default:
+		// The separator is the first '-' that follows a digit or space; a leading '-'
+		// is either the sign of the start or, with no separator, an open start
+		sep := -1
+		for i := 1; i < len(s); i++ {
+			if s[i] == '-' && (isDigit(s[i-1]) || s[i-1] == ' ') {
+				sep = i
+				break
+			}
+		}
+		switch {
+		case sep > 0:
+			b.start, b.end = s[:sep], s[sep+1:]
+		case strings.HasPrefix(s, "-"):
+			b.end = s[1:]
+		default:
+			return b, fmt.Errorf("invalid range format: %s", line)
+		}
+	}
+
+	b.start, b.end = strings.TrimSpace(b.start), strings.TrimSpace(b.end)
+	if b.start == "" && b.end == "" {
+		return b, fmt.Errorf("range has no bounds: %s", line)
+	}
+	return b, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Diagnostic is a problem in a worksheet that parsing worked around, such as a row with
+// a missing cell. Strict mode treats any diagnostic as an error.
+type Diagnostic struct {
+	Row, Col int // line and character position in the file, from 1
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("row %d, col %d: %s", d.Row, d.Col, d.Message)
+}
+
+func (g *Grid) diagnose(row, col int, format string, args ...any) {
+	g.Diagnostics = append(g.Diagnostics, Diagnostic{Row: row, Col: col, Message: fmt.Sprintf(format, args...)})
+}
+
+// splitFields splits line into fields the way strings.Fields does, separated by any
+// Unicode white space, and also returns the byte position of each field
+func splitFields(line string) (fields []string, starts []int) {
+	start := -1
+	for i, c := range line {
+		switch {
+		case unicode.IsSpace(c) && start >= 0:
+			fields = append(fields, line[start:i])
+			starts = append(starts, start)
+			start = -1
+		case !unicode.IsSpace(c) && start < 0:
+			start = i
+		}
+	}
+	if start >= 0 {
+		fields = append(fields, line[start:])
+		starts = append(starts, start)
+	}
+	return fields, starts
+}
+
+// diagnoseOriginal reports rows whose cell count differs from the operator count and
+// operators left without numbers. rowLines holds the file line of each row, starts the
+// position of each of its cells; the last row is the operator row.
+func (g *Grid) diagnoseOriginal(rows [][]string, rowLines []int, starts [][]int) {
+	numCols := len(g.Columns)
+	for i, row := range rows[:len(rows)-1] {
+		switch {
+		case len(row) < numCols:
+			last := len(row) - 1
+			missing := fmt.Sprintf("columns %d-%d get", len(row)+1, numCols)
+			if len(row)+1 == numCols {
+				missing = fmt.Sprintf("column %d gets", numCols)
+			}
+			g.diagnose(rowLines[i], starts[i][last]+len(row[last])+1,
+				"row has %d cells but there are %d operators; %s no number from it", len(row), numCols, missing)
+		case len(row) > numCols:
+			g.diagnose(rowLines[i], starts[i][numCols]+1,
+				"row has %d cells but there are %d operators; %q and the cells after it are ignored", len(row), numCols, row[numCols])
+		}
+	}
+
+	opRow := len(rows) - 1
+	for i, col := range g.Columns {
+		if len(col.Numbers) == 0 {
+			g.diagnose(rowLines[opRow], starts[opRow][i]+1, "operator %q has no numbers", col.Operator)
+		}
+	}
+}
+
+// diagnoseAligned reports characters that aligned mode reads in a way the layout probably
+// did not mean: characters left of the first operator (ignored), gaps in a number read down
+// a character column (joined up), numbers running across the start of the next column
+// (split between the two), and operators without numbers. lines are padded to the same width and
+// lineNums holds the file line of each; the last line is the operator line.
+func (g *Grid) diagnoseAligned(lines []string, lineNums []int, opPositions []int) {
+	numberLines := lines[:len(lines)-1]
+	opRow := lineNums[len(lines)-1]
+
+	firstOp := len(lines[0])
+	if len(opPositions) > 0 {
+		firstOp = opPositions[0]
+	}
+	for r, line := range numberLines {
+		if i := strings.IndexFunc(line[:firstOp], func(c rune) bool { return c != ' ' }); i >= 0 {
+			g.diagnose(lineNums[r], i+1, "%q is left of the first operator and is ignored", line[i])
+		}
+	}
+
+	for i, opPos := range opPositions {
+		end := len(lines[0])
+		if i+1 < len(opPositions) {
+			end = opPositions[i+1]
+		}
+
+		for pos := opPos; pos < end; pos++ {
+			first, last := -1, -1
+			for r, line := range numberLines {
+				if line[pos] != ' ' {
+					if first < 0 {
+						first = r
+					}
+					last = r
+				}
+			}
+			if first < 0 {
+				continue
+			}
+			for r := first + 1; r < last; r++ {
+				if numberLines[r][pos] == ' ' {
+					g.diagnose(lineNums[r], pos+1, "gap in the number read down this column; the characters above and below are joined")
+					break
+				}
+			}
+			if pos == end-1 && i+1 < len(opPositions) {
+				for r, line := range numberLines {
+					if line[pos] != ' ' && line[end] != ' ' {
+						g.diagnose(lineNums[r], pos+1, "number runs across the start of column %d; it is split between columns %d and %d", i+2, i+1, i+2)
+						break
+					}
+				}
+			}
+		}
+
+		if len(g.Columns[i].Numbers) == 0 {
+			g.diagnose(opRow, opPos+1, "operator %q has no numbers", g.Columns[i].Operator)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a node of a parsed column formula, evaluated against the column's numbers
+type Expr interface {
+	Eval(numbers []Value) (Value, error)
+	String() string
+}
+
+// numberExpr is an integer or decimal literal
+type numberExpr struct {
+	value Value
+}
+
+// refExpr refers to a number by position: a is the first number, b the second and so on
+type refExpr struct {
+	index int
+}
+
+// aggregateExpr combines every number in the column: sum, prod, mean, min, max or count
+type aggregateExpr struct {
+	name string
+}
+
+type negExpr struct {
+	operand Expr
+}
+
+// binaryExpr applies one of the column operators to two sub-expressions
+type binaryExpr struct {
+	op          string
+	left, right Expr
+}
+
+func (e numberExpr) Eval([]Value) (Value, error) {
+	return e.value, nil
+}
+
+func (e numberExpr) String() string {
+	return e.value.String()
+}
+
+func (e refExpr) Eval(numbers []Value) (Value, error) {
+	if e.index >= len(numbers) {
+		return Value{}, fmt.Errorf("%s refers to number %d but the column has %d", e, e.index+1, len(numbers))
+	}
+	return numbers[e.index], nil
+}
+
+func (e refExpr) String() string {
+	return string(rune('a' + e.index))
+}
+
+func (e aggregateExpr) Eval(numbers []Value) (Value, error) {
+	if e.name == "count" {
+		return IntValue(int64(len(numbers))), nil
+	}
+	if len(numbers) == 0 {
+		return Value{}, fmt.Errorf("%s of an empty column", e.name)
+	}
+
+	op := map[string]string{"sum": "+", "mean": "+", "prod": "*", "min": "min", "max": "max"}[e.name]
+	result, err := fold(numbers, operators[op])
+	if err != nil || e.name != "mean" {
+		return result, err
+	}
+	return result.Div(IntValue(int64(len(numbers))))
+}
+
+func (e aggregateExpr) String() string {
+	return e.name
+}
+
+func (e negExpr) Eval(numbers []Value) (Value, error) {
+	v, err := e.operand.Eval(numbers)
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{}.Sub(v)
+}
+
+func (e negExpr) String() string {
+	return "(-" + e.operand.String() + ")"
+}
+
+func (e binaryExpr) Eval(numbers []Value) (Value, error) {
+	left, err := e.left.Eval(numbers)
+	if err != nil {
+		return Value{}, err
+	}
+	right, err := e.right.Eval(numbers)
+	if err != nil {
+		return Value{}, err
+	}
+	return operators[e.op](left, right)
+}
+
+func (e binaryExpr) String() string {
+	return "(" + e.left.String() + " " + e.op + " " + e.right.String() + ")"
+}
+
+// fold applies an operator between numbers from first to last
+func fold(numbers []Value, apply func(a, b Value) (Value, error)) (Value, error) {
+	result := numbers[0]
+	for i := 1; i < len(numbers); i++ {
+		var err error
+		if result, err = apply(result, numbers[i]); err != nil {
+			return Value{}, fmt.Errorf("at number %d: %w", i+1, err)
+		}
+	}
+	return result, nil
+}
+
+// ParseFormula parses a column formula such as "a*b+c", "sum" or "(a+b)/count".
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | power
+//	power   = primary [ "^" unary ]            (right associative, so -a^2 is -(a^2))
+//	primary = number | letter | aggregate | "(" expr ")"
+//
+// Letters a to z refer to the column's numbers by position; the aggregates are sum,
+// prod, mean, min, max and count. Formulas cannot contain spaces.
+func ParseFormula(s string) (Expr, error) {
+	p := &formulaParser{src: s}
+	expr, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(s) {
+		return nil, p.errorf("unexpected %q", s[p.pos])
+	}
+	return expr, nil
+}
+
+type formulaParser struct {
+	src string
+	pos int
+}
+
+func (p *formulaParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("formula %q at offset %d: %s", p.src, p.pos, fmt.Sprintf(format, args...))
+}
+
+// accept consumes c if it is next
+func (p *formulaParser) accept(c byte) bool {
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *formulaParser) expr() (Expr, error) {
+	left, err := p.term()
+	for err == nil && p.pos < len(p.src) && strings.IndexByte("+-", p.src[p.pos]) >= 0 {
+		op := string(p.src[p.pos])
+		p.pos++
+		var right Expr
+		if right, err = p.term(); err == nil {
+			left = binaryExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *formulaParser) term() (Expr, error) {
+	left, err := p.unary()
+	for err == nil && p.pos < len(p.src) && strings.IndexByte("*/%", p.src[p.pos]) >= 0 {
+		op := string(p.src[p.pos])
+		p.pos++
+		var right Expr
+		if right, err = p.unary(); err == nil {
+			left = binaryExpr{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *formulaParser) unary() (Expr, error) {
+	if p.accept('-') {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{operand: operand}, nil
+	}
+	return p.power()
+}
+
+func (p *formulaParser) power() (Expr, error) {
+	base, err := p.primary()
+	if err != nil || !p.accept('^') {
+		return base, err
+	}
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return binaryExpr{op: "^", left: base, right: exponent}, nil
+}
+
+func (p *formulaParser) primary() (Expr, error) {
+	if p.pos >= len(p.src) {
+		return nil, p.errorf("unexpected end")
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c == '(':
+		p.pos++
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, p.errorf("expected ')'")
+		}
+		return inner, nil
+
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := ParseValue(p.src[start:p.pos])
+		if err != nil || n.IsBig() {
+			return nil, p.errorf("invalid number %s", p.src[start:p.pos])
+		}
+		return numberExpr{value: n}, nil
+
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.src) && p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		switch name {
+		case "sum", "prod", "mean", "min", "max", "count":
+			return aggregateExpr{name: name}, nil
+		}
+		if len(name) == 1 {
+			return refExpr{index: int(c - 'a')}, nil
+		}
+		p.pos = start
+		return nil, p.errorf("unknown name %q", name)
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
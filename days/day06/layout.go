@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// minLayoutConfidence is the confidence auto mode needs before it picks a layout
+const minLayoutConfidence = 0.75
+
+// Layout is the result of detecting which mode a worksheet was written for
+type Layout struct {
+	Mode       string  // "original" or "aligned"
+	Confidence float64 // from 0 to 1
+	Reason     string
+}
+
+func (l Layout) String() string {
+	return fmt.Sprintf("%s (confidence %.0f%%: %s)", l.Mode, l.Confidence*100, l.Reason)
+}
+
+// span is an inclusive range of character positions
+type span struct {
+	start, end int
+}
+
+// DetectLayout decides whether a worksheet is meant to be read in original or aligned
+// mode. A layout that cannot be read is ruled out. When both can, the alignment of the
+// numbers decides: blocks whose numbers share neither a left nor a right edge, or that
+// are justified differently from the other blocks, only make sense if positions matter.
+// Consistently justified blocks look like an ordinary table. Inputs with too little
+// evidence either way are refused.
+func DetectLayout(r io.Reader) (Layout, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Layout{}, err
+	}
+	if len(lines) < 2 {
+		return Layout{}, fmt.Errorf("file must contain at least 2 rows")
+	}
+
+	originalErr := checkOriginalLayout(lines)
+	blocks, alignedErr := checkAlignedLayout(lines)
+
+	switch {
+	case originalErr != nil && alignedErr != nil:
+		return Layout{}, fmt.Errorf("neither layout fits: original: %v; aligned: %v", originalErr, alignedErr)
+	case alignedErr != nil:
+		return Layout{Mode: "original", Confidence: 1, Reason: "aligned reading fails: " + alignedErr.Error()}, nil
+	case originalErr != nil:
+		return Layout{Mode: "aligned", Confidence: 1, Reason: "original reading fails: " + originalErr.Error()}, nil
+	}
+
+	var left, right, ragged int
+	for _, b := range blocks {
+		switch justification(lines[:len(lines)-1], b) {
+		case "left":
+			left++
+		case "right":
+			right++
+		case "ragged":
+			ragged++
+		}
+	}
+	decisive := left + right + ragged
+	if decisive == 0 {
+		return Layout{}, fmt.Errorf("ambiguous layout: both readings fit and every number fills its column; give the mode explicitly")
+	}
+
+	positional := ragged + min(left, right)
+	score := float64(positional) / float64(decisive)
+	layout := Layout{
+		Mode:       "aligned",
+		Confidence: score,
+		Reason:     fmt.Sprintf("%d of %d blocks are ragged or justified against the rest", positional, decisive),
+	}
+	if score < 0.5 {
+		layout.Mode, layout.Confidence = "original", 1-score
+	}
+	if layout.Confidence < minLayoutConfidence {
+		return Layout{}, fmt.Errorf("ambiguous layout: both readings fit and the alignment only suggests %s; give the mode explicitly", layout)
+	}
+	return layout, nil
+}
+
+// detectFileLayout runs DetectLayout on a file
+func detectFileLayout(filepath string) (Layout, error) {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return Layout{}, err
+	}
+	defer file.Close()
+	return DetectLayout(file)
+}
+
+// checkOriginalLayout reports why the lines cannot be read in original mode: every number
+// row must have one field per operator, and every field must parse
+func checkOriginalLayout(lines []string) error {
+	numCols := len(strings.Fields(lines[len(lines)-1]))
+	for i, line := range lines[:len(lines)-1] {
+		if n := len(strings.Fields(line)); n != numCols {
+			return fmt.Errorf("row %d has %d fields but there are %d operators", i+1, n, numCols)
+		}
+	}
+	_, err := parseOriginalMode(strings.NewReader(strings.Join(lines, "\n")))
+	return err
+}
+
+// checkAlignedLayout reports why the lines cannot be read in aligned mode, or returns the
+// blocks of number characters. Blank character columns must split the numbers into one
+// block per operator, each operator at or left of its block and right of the one before.
+func checkAlignedLayout(lines []string) ([]span, error) {
+	numberLines := lines[:len(lines)-1]
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line))
+	}
+
+	var blocks []span
+	for pos := 0; pos < width; pos++ {
+		blank := true
+		for _, line := range numberLines {
+			if pos < len(line) && line[pos] != ' ' {
+				blank = false
+				break
+			}
+		}
+		switch {
+		case blank:
+		case len(blocks) > 0 && blocks[len(blocks)-1].end == pos-1:
+			blocks[len(blocks)-1].end = pos
+		default:
+			blocks = append(blocks, span{pos, pos})
+		}
+	}
+
+	opLine := lines[len(lines)-1]
+	var opPositions []int
+	for pos := 0; pos < len(opLine); pos++ {
+		if opLine[pos] != ' ' && (pos == 0 || opLine[pos-1] == ' ') {
+			opPositions = append(opPositions, pos)
+		}
+	}
+	if len(blocks) != len(opPositions) {
+		return nil, fmt.Errorf("%d blocks of numbers but %d operators", len(blocks), len(opPositions))
+	}
+	for i, b := range blocks {
+		if opPositions[i] > b.start || (i > 0 && opPositions[i] <= blocks[i-1].end) {
+			return nil, fmt.Errorf("operator %d at position %d is not at the start of the numbers at %d-%d", i+1, opPositions[i], b.start, b.end)
+		}
+	}
+
+	if _, err := parseAlignedMode(strings.NewReader(strings.Join(lines, "\n"))); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// justification classifies a block by how its numbers line up: "left" or "right" when
+// they share only that edge, "ragged" when they share neither, and "" when there is no
+// evidence (a single number, or numbers that all fill the same positions)
+func justification(numberLines []string, b span) string {
+	var lefts, rights []int
+	for _, line := range numberLines {
+		if b.start >= len(line) {
+			continue
+		}
+		text := line[b.start:min(b.end+1, len(line))]
+		if trimmed := strings.TrimLeft(text, " "); trimmed != "" {
+			lefts = append(lefts, b.start+len(text)-len(trimmed))
+			rights = append(rights, b.start+len(strings.TrimRight(text, " "))-1)
+		}
+	}
+	if len(lefts) < 2 {
+		return ""
+	}
+
+	sameLeft, sameRight := true, true
+	for i := 1; i < len(lefts); i++ {
+		sameLeft = sameLeft && lefts[i] == lefts[0]
+		sameRight = sameRight && rights[i] == rights[0]
+	}
+	switch {
+	case sameLeft && sameRight:
+		return ""
+	case sameLeft:
+		return "left"
+	case sameRight:
+		return "right"
+	}
+	return "ragged"
+}
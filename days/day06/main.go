@@ -2,46 +2,64 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"strings"
 )
 
 type Column struct {
-	Numbers  []int
+	Numbers  []Value
 	Operator string
+	Formula  Expr // set when Operator is a formula rather than a single operator
 }
 
-func (c *Column) Calculate() int {
-	if len(c.Numbers) == 0 {
-		return 0
+// SetOperator sets the column's operator, parsing it as a formula when it is not one of
+// the single operators
+func (c *Column) SetOperator(op string) error {
+	c.Operator = op
+	c.Formula = nil
+	if _, ok := operators[op]; ok {
+		return nil
 	}
+	formula, err := ParseFormula(op)
+	if err != nil {
+		return fmt.Errorf("unknown operator or invalid formula: %w", err)
+	}
+	c.Formula = formula
+	return nil
+}
 
-	result := c.Numbers[0]
-	for i := 1; i < len(c.Numbers); i++ {
-		switch c.Operator {
-		case "+":
-			result += c.Numbers[i]
-		case "*":
-			result *= c.Numbers[i]
-		}
+// Calculate folds the column's numbers with its operator, or evaluates its formula, in
+// int64 while the results fit and math/big once they overflow
+func (c *Column) Calculate() (Value, error) {
+	numbers := c.Numbers
+	if c.Formula != nil {
+		return c.Formula.Eval(numbers)
+	}
+
+	apply, err := lookupOperator(c.Operator)
+	if err != nil {
+		return Value{}, err
+	}
+	if len(numbers) == 0 {
+		return Value{}, nil
 	}
-	return result
+	result, err := fold(numbers, apply)
+	if err != nil {
+		return Value{}, fmt.Errorf("%s %w", c.Operator, err)
+	}
+	return result, nil
 }
 
 type Grid struct {
-	Columns []Column
+	Columns     []Column
+	Diagnostics []Diagnostic // problems parsing worked around, in file order per check
 }
 
-func (g *Grid) CalculateTotal() int {
-	total := 0
-	for i, col := range g.Columns {
-		columnTotal := col.Calculate()
-		fmt.Printf("Column %d: %d\n", i+1, columnTotal)
-		total += columnTotal
-	}
-	return total
+func (g *Grid) CalculateTotal() (Value, error) {
+	return g.Render(os.Stdout, FormatColumns)
 }
 
 func parseFile(filepath string, mode string) (*Grid, error) {
@@ -60,18 +78,22 @@ func parseFile(filepath string, mode string) (*Grid, error) {
 	return nil, fmt.Errorf("invalid mode: %s (must be 'original' or 'aligned')", mode)
 }
 
-func parseOriginalMode(file *os.File) (*Grid, error) {
+func parseOriginalMode(r io.Reader) (*Grid, error) {
 	var rows [][]string
-	scanner := bufio.NewScanner(file)
+	var rowLines []int   // file line of each row
+	var rowStarts [][]int // position of each cell in its line
+	scanner := bufio.NewScanner(r)
 	
-	for scanner.Scan() {
+	for lineNum := 1; scanner.Scan(); lineNum++ {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
-		fields := strings.Fields(line)
+		fields, starts := splitFields(scanner.Text())
 		if len(fields) > 0 {
 			rows = append(rows, fields)
+			rowLines = append(rowLines, lineNum)
+			rowStarts = append(rowStarts, starts)
 		}
 	}
 
@@ -92,31 +114,36 @@ func parseOriginalMode(file *os.File) (*Grid, error) {
 	}
 
 	for colIdx := 0; colIdx < numCols; colIdx++ {
-		grid.Columns[colIdx].Operator = operatorRow[colIdx]
-		grid.Columns[colIdx].Numbers = make([]int, 0)
+		if err := grid.Columns[colIdx].SetOperator(operatorRow[colIdx]); err != nil {
+			return nil, fmt.Errorf("column %d: %w", colIdx+1, err)
+		}
+		grid.Columns[colIdx].Numbers = make([]Value, 0)
 
-		for _, row := range numberRows {
+		for rowIdx, row := range numberRows {
 			if colIdx < len(row) {
-				num, err := strconv.Atoi(row[colIdx])
+				num, err := ParseValue(row[colIdx])
 				if err != nil {
-					return nil, fmt.Errorf("invalid number at column %d: %s", colIdx+1, row[colIdx])
+					return nil, fmt.Errorf("invalid number at column %d (row %d, col %d): %s", colIdx+1, rowLines[rowIdx], rowStarts[rowIdx][colIdx]+1, row[colIdx])
 				}
 				grid.Columns[colIdx].Numbers = append(grid.Columns[colIdx].Numbers, num)
 			}
 		}
 	}
 
+	grid.diagnoseOriginal(rows, rowLines, rowStarts)
 	return grid, nil
 }
 
-func parseAlignedMode(file *os.File) (*Grid, error) {
+func parseAlignedMode(r io.Reader) (*Grid, error) {
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	var lineNums []int
+	scanner := bufio.NewScanner(r)
 	
-	for scanner.Scan() {
+	for lineNum := 1; scanner.Scan(); lineNum++ {
 		line := scanner.Text()
 		if strings.TrimSpace(line) != "" {
 			lines = append(lines, line)
+			lineNums = append(lineNums, lineNum)
 		}
 	}
 
@@ -144,13 +171,18 @@ func parseAlignedMode(file *os.File) (*Grid, error) {
 	operatorLine := lines[len(lines)-1]
 	numberLines := lines[:len(lines)-1]
 
+	// Each operator starts a column; operators such as "min" span several characters
 	var opPositions []int
 	operators := make(map[int]string)
 
 	for charIdx := 0; charIdx < len(operatorLine); charIdx++ {
-		if operatorLine[charIdx] != ' ' {
+		if operatorLine[charIdx] != ' ' && (charIdx == 0 || operatorLine[charIdx-1] == ' ') {
 			opPositions = append(opPositions, charIdx)
-			operators[charIdx] = string(operatorLine[charIdx])
+			end := charIdx
+			for end < len(operatorLine) && operatorLine[end] != ' ' {
+				end++
+			}
+			operators[charIdx] = operatorLine[charIdx:end]
 		}
 	}
 
@@ -166,8 +198,10 @@ func parseAlignedMode(file *os.File) (*Grid, error) {
 		}
 
 		col := Column{
-			Operator: operators[opPos],
-			Numbers:  make([]int, 0),
+			Numbers: make([]Value, 0),
+		}
+		if err := col.SetOperator(operators[opPos]); err != nil {
+			return nil, fmt.Errorf("column %d: %w", i+1, err)
 		}
 
 		for pos := end - 1; pos >= start; pos-- {
@@ -180,9 +214,9 @@ func parseAlignedMode(file *os.File) (*Grid, error) {
 
 			if len(digits) > 0 {
 				numStr := string(digits)
-				num, err := strconv.Atoi(numStr)
+				num, err := ParseValue(numStr)
 				if err != nil {
-					return nil, fmt.Errorf("invalid number at position %d: %s", pos, numStr)
+					return nil, fmt.Errorf("invalid number read down col %d: %s", pos+1, numStr)
 				}
 				col.Numbers = append(col.Numbers, num)
 			}
@@ -191,30 +225,76 @@ func parseAlignedMode(file *os.File) (*Grid, error) {
 		grid.Columns = append(grid.Columns, col)
 	}
 
+	grid.diagnoseAligned(lines, lineNums, opPositions)
 	return grid, nil
 }
 
 func main() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: day6 <mode> <filepath>")
-		fmt.Println("  mode: 'original' or 'aligned'")
+		fmt.Println("Usage: day6 <mode> <filepath> [-format columns|equations|table|csv] [-strict]")
+		fmt.Println("  mode: 'original', 'aligned' or 'auto'")
 		os.Exit(1)
 	}
 
 	mode := os.Args[1]
 	filepath := os.Args[2]
 	
-	if mode != "original" && mode != "aligned" {
-		fmt.Fprintf(os.Stderr, "Invalid mode: %s (must be 'original' or 'aligned')\n", mode)
+	if mode != "original" && mode != "aligned" && mode != "auto" {
+		fmt.Fprintf(os.Stderr, "Invalid mode: %s (must be 'original', 'aligned' or 'auto')\n", mode)
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("day6", flag.ContinueOnError)
+	format := fs.String("format", FormatColumns, "output format: columns, equations, table or csv")
+	strict := fs.Bool("strict", false, "fail on ragged or misaligned worksheets instead of warning")
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected argument %q\n", fs.Arg(0))
+		os.Exit(1)
+	}
+	switch *format {
+	case FormatColumns, FormatEquations, FormatTable, FormatCSV:
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid format: %s (must be 'columns', 'equations', 'table' or 'csv')\n", *format)
 		os.Exit(1)
 	}
+
+	if mode == "auto" {
+		layout, err := detectFileLayout(filepath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error detecting layout: %v\n", err)
+			os.Exit(1)
+		}
+		// Keep CSV output machine-readable
+		if *format == FormatCSV {
+			fmt.Fprintf(os.Stderr, "Layout: %s\n", layout)
+		} else {
+			fmt.Printf("Layout: %s\n", layout)
+		}
+		mode = layout.Mode
+	}
 	
 	grid, err := parseFile(filepath, mode)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing file: %v\n", err)
 		os.Exit(1)
 	}
+	for _, d := range grid.Diagnostics {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", d)
+	}
+	if *strict && len(grid.Diagnostics) > 0 {
+		fmt.Fprintf(os.Stderr, "Error parsing file: problems found in the worksheet: %d (strict mode)\n", len(grid.Diagnostics))
+		os.Exit(1)
+	}
 
-	total := grid.CalculateTotal()
-	fmt.Printf("Total: %d\n", total)
+	total, err := grid.Render(os.Stdout, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error calculating: %v\n", err)
+		os.Exit(1)
+	}
+	if *format != FormatCSV {
+		fmt.Printf("Total: %s\n", total)
+	}
 }
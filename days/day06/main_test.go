@@ -3,6 +3,8 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -15,7 +17,7 @@ func TestColumnCalculate(t *testing.T) {
 		{
 			name: "multiplication",
 			column: Column{
-				Numbers:  []int{123, 45, 6},
+				Numbers:  ints(123, 45, 6),
 				Operator: "*",
 			},
 			expected: 33210,
@@ -23,7 +25,7 @@ func TestColumnCalculate(t *testing.T) {
 		{
 			name: "addition",
 			column: Column{
-				Numbers:  []int{328, 64, 98},
+				Numbers:  ints(328, 64, 98),
 				Operator: "+",
 			},
 			expected: 490,
@@ -31,7 +33,7 @@ func TestColumnCalculate(t *testing.T) {
 		{
 			name: "empty column",
 			column: Column{
-				Numbers:  []int{},
+				Numbers:  ints(),
 				Operator: "+",
 			},
 			expected: 0,
@@ -39,7 +41,7 @@ func TestColumnCalculate(t *testing.T) {
 		{
 			name: "single number",
 			column: Column{
-				Numbers:  []int{42},
+				Numbers:  ints(42),
 				Operator: "*",
 			},
 			expected: 42,
@@ -48,9 +50,12 @@ func TestColumnCalculate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := tt.column.Calculate()
-			if result != tt.expected {
-				t.Errorf("Calculate() = %d, want %d", result, tt.expected)
+			result, err := tt.column.Calculate()
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			if result.Cmp(IntValue(int64(tt.expected))) != 0 {
+				t.Errorf("Calculate() = %s, want %d", result, tt.expected)
 			}
 		})
 	}
@@ -59,18 +64,21 @@ func TestColumnCalculate(t *testing.T) {
 func TestGridCalculateTotal(t *testing.T) {
 	grid := &Grid{
 		Columns: []Column{
-			{Numbers: []int{123, 45, 6}, Operator: "*"},
-			{Numbers: []int{328, 64, 98}, Operator: "+"},
-			{Numbers: []int{51, 387, 215}, Operator: "*"},
-			{Numbers: []int{64, 23, 314}, Operator: "+"},
+			{Numbers: ints(123, 45, 6), Operator: "*"},
+			{Numbers: ints(328, 64, 98), Operator: "+"},
+			{Numbers: ints(51, 387, 215), Operator: "*"},
+			{Numbers: ints(64, 23, 314), Operator: "+"},
 		},
 	}
 
 	expected := 4277556
-	result := grid.CalculateTotal()
+	result, err := grid.CalculateTotal()
+	if err != nil {
+		t.Fatalf("CalculateTotal() error = %v", err)
+	}
 	
-	if result != expected {
-		t.Errorf("CalculateTotal() = %d, want %d", result, expected)
+	if result.Cmp(IntValue(int64(expected))) != 0 {
+		t.Errorf("CalculateTotal() = %s, want %d", result, expected)
 	}
 }
 
@@ -103,9 +111,12 @@ func TestParseFileOriginalMode(t *testing.T) {
 	}
 
 	expectedTotal := 4277556
-	result := grid.CalculateTotal()
-	if result != expectedTotal {
-		t.Errorf("Total = %d, want %d", result, expectedTotal)
+	result, err := grid.CalculateTotal()
+	if err != nil {
+		t.Fatalf("CalculateTotal() error = %v", err)
+	}
+	if result.Cmp(IntValue(int64(expectedTotal))) != 0 {
+		t.Errorf("Total = %s, want %d", result, expectedTotal)
 	}
 }
 
@@ -138,9 +149,12 @@ func TestParseFileAlignedMode(t *testing.T) {
 	}
 
 	expectedTotal := 3263827
-	result := grid.CalculateTotal()
-	if result != expectedTotal {
-		t.Errorf("Total = %d, want %d", result, expectedTotal)
+	result, err := grid.CalculateTotal()
+	if err != nil {
+		t.Fatalf("CalculateTotal() error = %v", err)
+	}
+	if result.Cmp(IntValue(int64(expectedTotal))) != 0 {
+		t.Errorf("Total = %s, want %d", result, expectedTotal)
 	}
 }
 
@@ -157,9 +171,12 @@ func TestParseFileWithExampleDataOriginal(t *testing.T) {
 	}
 
 	expectedTotal := 4277556
-	result := grid.CalculateTotal()
-	if result != expectedTotal {
-		t.Errorf("Total from example-data.txt in original mode = %d, want %d", result, expectedTotal)
+	result, err := grid.CalculateTotal()
+	if err != nil {
+		t.Fatalf("CalculateTotal() error = %v", err)
+	}
+	if result.Cmp(IntValue(int64(expectedTotal))) != 0 {
+		t.Errorf("Total from example-data.txt in original mode = %s, want %d", result, expectedTotal)
 	}
 }
 
@@ -176,8 +193,560 @@ func TestParseFileWithExampleDataAligned(t *testing.T) {
 	}
 
 	expectedTotal := 3263827
-	result := grid.CalculateTotal()
-	if result != expectedTotal {
-		t.Errorf("Total from example-data.txt in aligned mode = %d, want %d", result, expectedTotal)
+	result, err := grid.CalculateTotal()
+	if err != nil {
+		t.Fatalf("CalculateTotal() error = %v", err)
+	}
+	if result.Cmp(IntValue(int64(expectedTotal))) != 0 {
+		t.Errorf("Total from example-data.txt in aligned mode = %s, want %d", result, expectedTotal)
+	}
+}
+
+func TestColumnCalculateOperators(t *testing.T) {
+	tests := []struct {
+		operator string
+		numbers  []int
+		expected string
+	}{
+		{"-", []int{100, 30, 5}, "65"},
+		{"-", []int{5, 30}, "-25"},
+		{"/", []int{100, 4, 2}, "12.5"},
+		{"/", []int{-7, 2}, "-3.5"},
+		{"/", []int{100, 3, 2}, "16.666666666666666666665"},
+		{"%", []int{100, 7}, "2"},
+		{"%", []int{-7, 2}, "-1"},
+		{"min", []int{51, 387, 215}, "51"},
+		{"max", []int{51, 387, 215}, "387"},
+		{"^", []int{2, 3, 2}, "64"},
+		{"^", []int{7, 0}, "1"},
+		// Overflowing int64 falls back to math/big
+		{"*", []int{9223372036854775807, 2}, "18446744073709551614"},
+		{"+", []int{9223372036854775807, 1}, "9223372036854775808"},
+		{"-", []int{-9223372036854775808, 1}, "-9223372036854775809"},
+		{"/", []int{-9223372036854775808, -1}, "9223372036854775808"},
+		{"^", []int{2, 64}, "18446744073709551616"},
+		{"^", []int{10, 30}, "1000000000000000000000000000000"},
+		{"^", []int{-1, 1000000000001}, "-1"},
+		// Once big, later operations stay exact
+		{"*", []int{4294967296, 4294967296, 4294967296}, "79228162514264337593543950336"},
+		{"/", []int{9223372036854775807, 1}, "9223372036854775807"},
+	}
+
+	for _, tt := range tests {
+		col := Column{Numbers: ints(tt.numbers...), Operator: tt.operator}
+		result, err := col.Calculate()
+		if err != nil {
+			t.Errorf("%v %s: unexpected error %v", tt.numbers, tt.operator, err)
+			continue
+		}
+		if result.String() != tt.expected {
+			t.Errorf("%v %s = %s, want %s", tt.numbers, tt.operator, result, tt.expected)
+		}
+	}
+}
+
+func TestColumnCalculateErrors(t *testing.T) {
+	tests := []struct {
+		operator string
+		numbers  []int
+	}{
+		{"&", []int{1, 2}},
+		{"&", []int{}},
+		{"/", []int{1, 0}},
+		{"%", []int{1, 0}},
+		{"^", []int{2, -1}},
+		{"^", []int{2, 9223372036854775807}},
+		{"^", []int{10, 1000000, 1000000}},
+	}
+
+	for _, tt := range tests {
+		col := Column{Numbers: ints(tt.numbers...), Operator: tt.operator}
+		if result, err := col.Calculate(); err == nil {
+			t.Errorf("%v %s = %s, expected error", tt.numbers, tt.operator, result)
+		}
+	}
+}
+
+func TestParseAlignedModeWordOperators(t *testing.T) {
+	content := "12 3  40\n 5 17  2\nmin max -\n"
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	grid, err := parseFile(path, "aligned")
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+
+	expected := []string{"min", "max", "-"}
+	if len(grid.Columns) != len(expected) {
+		t.Fatalf("Expected %d columns, got %d", len(expected), len(grid.Columns))
+	}
+	for i, op := range expected {
+		if grid.Columns[i].Operator != op {
+			t.Errorf("Column %d operator = %q, want %q", i+1, grid.Columns[i].Operator, op)
+		}
+	}
+}
+
+func TestParseFormula(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectError bool
+	}{
+		{"a*b+c", "((a * b) + c)", false},
+		{"a+b*c", "(a + (b * c))", false},
+		{"(a+b)*c", "((a + b) * c)", false},
+		{"a-b-c", "((a - b) - c)", false},
+		{"a^b^c", "(a ^ (b ^ c))", false},
+		{"a^-b", "(a ^ (-b))", false},
+		{"-a^2", "(-(a ^ 2))", false},
+		{"sum/count", "(sum / count)", false},
+		{"prod%1000", "(prod % 1000)", false},
+		{"mean", "mean", false},
+		{"", "", true},
+		{"a+", "", true},
+		{"(a+b", "", true},
+		{"a)", "", true},
+		{"ab", "", true},
+		{"total", "", true},
+		{"a&b", "", true},
+		{"99999999999999999999", "", true},
+	}
+
+	for _, tt := range tests {
+		expr, err := ParseFormula(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("ParseFormula(%q) = %s, expected error", tt.input, expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseFormula(%q) unexpected error: %v", tt.input, err)
+		} else if expr.String() != tt.expected {
+			t.Errorf("ParseFormula(%q) = %s, want %s", tt.input, expr, tt.expected)
+		}
+	}
+}
+
+func TestColumnCalculateFormula(t *testing.T) {
+	tests := []struct {
+		formula     string
+		numbers     []int
+		expected    string
+		expectError bool
+	}{
+		{"a*b+c", []int{123, 45, 6}, "5541", false},
+		{"sum", []int{328, 64, 98}, "490", false},
+		{"prod", []int{123, 45, 6}, "33210", false},
+		{"mean", []int{64, 23, 314}, "133.66666666666666666666", false},
+		{"max-min", []int{51, 387, 215}, "336", false},
+		{"count", []int{1, 2, 3, 4}, "4", false},
+		{"(a+c)^2-b", []int{10, 20, 30}, "1580", false},
+		{"-a", []int{5}, "-5", false},
+		{"a^b", []int{2, 100}, "1267650600228229401496703205376", false},
+		{"d", []int{1, 2, 3}, "", true},
+		{"a/b", []int{1, 0}, "", true},
+		{"mean", []int{}, "", true},
+	}
+
+	for _, tt := range tests {
+		col := Column{Numbers: ints(tt.numbers...)}
+		if err := col.SetOperator(tt.formula); err != nil {
+			t.Fatalf("SetOperator(%q) unexpected error: %v", tt.formula, err)
+		}
+		if col.Formula == nil {
+			t.Fatalf("SetOperator(%q) did not parse a formula", tt.formula)
+		}
+
+		result, err := col.Calculate()
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("%s on %v = %s, expected error", tt.formula, tt.numbers, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s on %v unexpected error: %v", tt.formula, tt.numbers, err)
+		} else if result.String() != tt.expected {
+			t.Errorf("%s on %v = %s, want %s", tt.formula, tt.numbers, result, tt.expected)
+		}
+	}
+}
+
+func TestParseFileFormulas(t *testing.T) {
+	tests := []struct {
+		mode    string
+		content string
+		columns []string
+	}{
+		{
+			// The example grid: 123*45+6, 328+64+98, 387-51, (64+23+314)/3
+			"original",
+			"123 328  51 64 \n 45 64  387 23 \n  6 98  215 314\na*b+c sum max-min mean\n",
+			[]string{"5541", "490", "336", "133.66666666666666666666"},
+		},
+		{
+			// Columns wide enough for their formulas: 356*24+1, 8+248+369
+			"aligned",
+			"123   328  \n 45   64   \n  6   98   \na*b+c sum  \n",
+			[]string{"8545", "625"},
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "formulas.txt")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		grid, err := parseFile(path, tt.mode)
+		if err != nil {
+			t.Fatalf("parseFile(%s) error = %v", tt.mode, err)
+		}
+		if len(grid.Columns) != len(tt.columns) {
+			t.Fatalf("parseFile(%s): expected %d columns, got %d", tt.mode, len(tt.columns), len(grid.Columns))
+		}
+		for i, expected := range tt.columns {
+			result, err := grid.Columns[i].Calculate()
+			if err != nil {
+				t.Errorf("%s column %d: unexpected error: %v", tt.mode, i+1, err)
+			} else if result.String() != expected {
+				t.Errorf("%s column %d = %s, want %s", tt.mode, i+1, result, expected)
+			}
+		}
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.txt")
+	if err := os.WriteFile(bad, []byte("1 2\n3 4\n+ a+\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []string{"original", "aligned"} {
+		if _, err := parseFile(bad, mode); err == nil {
+			t.Errorf("parseFile(%s) with an invalid formula expected error", mode)
+		}
+	}
+}
+
+func TestDetectLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		mode    string // empty when the input should be refused
+	}{
+		{"fields not in blocks", "1 2\n33 4\n+ *\n", "original"},
+		{"consistently left-justified", "12  3\n4   56\n+   *\n", "original"},
+		{"mostly right-justified", "123 328  51 64 \n 45  64 387 23 \n  6  98 215 314\n*   +   *   +  \n", "original"},
+		{"ragged block", "12 \n 3 \n  4\n+  \n", "aligned"},
+		{"uneven field counts", "12  34\n5    6\n 7    \n+   * \n", "aligned"},
+		{"example data, justified both ways", "123 328  51 64 \n 45 64  387 23 \n  6 98  215 314\n*   +   *   +  \n", ""},
+		{"no alignment evidence", "12 34\n56 78\n+  * \n", ""},
+		{"neither layout fits", "1 x\n2 3\n+ *\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := DetectLayout(strings.NewReader(tt.content))
+			if tt.mode == "" {
+				if err == nil {
+					t.Errorf("DetectLayout() = %s, want error", layout)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DetectLayout() error = %v", err)
+			}
+			if layout.Mode != tt.mode || layout.Confidence < minLayoutConfidence {
+				t.Errorf("DetectLayout() = %s, want %s", layout, tt.mode)
+			}
+		})
+	}
+}
+
+func TestColumnEquation(t *testing.T) {
+	tests := []struct {
+		column   Column
+		expected string
+	}{
+		{Column{Numbers: ints(123, 45, 6), Operator: "*"}, "123 * 45 * 6 = 33210"},
+		{Column{Numbers: ints(9, 4), Operator: "min"}, "9 min 4 = 4"},
+		{Column{Numbers: ints(), Operator: "+"}, "no numbers = 0"},
+		{Column{Numbers: ints(123, 45, 6), Operator: "a*b+c"}, "a*b+c on 123, 45, 6 = 5541"},
+	}
+
+	for _, tt := range tests {
+		col := tt.column
+		if err := col.SetOperator(col.Operator); err != nil {
+			t.Fatal(err)
+		}
+		result, err := col.Calculate()
+		if err != nil {
+			t.Fatalf("%s: Calculate() error = %v", tt.expected, err)
+		}
+		if got := col.Equation(result); got != tt.expected {
+			t.Errorf("Equation() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestGridRender(t *testing.T) {
+	grid := &Grid{Columns: []Column{
+		{Numbers: ints(356, 24, 1), Operator: "*"},
+		{Numbers: ints(8, 248), Operator: "+"},
+	}}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{FormatColumns, "Column 1: 8544\nColumn 2: 256\n"},
+		{FormatEquations, "Column 1: 356 * 24 * 1 = 8544\nColumn 2: 8 + 248 = 256\n"},
+		{FormatTable, " 356   8\n  24 248\n   1    \n   *   +\n---- ---\n8544 256\n"},
+		{FormatCSV, "column,operator,numbers,result\n1,*,356 24 1,8544\n2,+,8 248,256\n"},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		total, err := grid.Render(&out, tt.format)
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", tt.format, err)
+		}
+		if total.Cmp(IntValue(8800)) != 0 {
+			t.Errorf("Render(%s) total = %s, want 8800", tt.format, total)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("Render(%s) =\n%s\nwant\n%s", tt.format, out.String(), tt.expected)
+		}
+	}
+
+	if _, err := grid.Render(&strings.Builder{}, "xml"); err == nil {
+		t.Error("Render(xml) expected error")
+	}
+}
+
+// ints converts integers to Values for column literals
+func ints(ns ...int) []Value {
+	values := make([]Value, len(ns))
+	for i, n := range ns {
+		values[i] = IntValue(int64(n))
+	}
+	return values
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"42", "42"},
+		{"-7", "-7"},
+		{"+7", "7"},
+		{"3.25", "3.25"},
+		{"-0.5", "-0.5"},
+		{"1.50", "1.50"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"123456789012345678901234567890.5", "123456789012345678901234567890.5"},
+	}
+	for _, tt := range tests {
+		v, err := ParseValue(tt.input)
+		if err != nil {
+			t.Errorf("ParseValue(%q) error = %v", tt.input, err)
+		} else if v.String() != tt.expected {
+			t.Errorf("ParseValue(%q) = %s, want %s", tt.input, v, tt.expected)
+		}
+	}
+
+	for _, input := range []string{"", "-", ".", "1.2.3", "--1", "1-", "1e5", "12a"} {
+		if v, err := ParseValue(input); err == nil {
+			t.Errorf("ParseValue(%q) = %s, expected error", input, v)
+		}
+	}
+}
+
+func TestColumnCalculateDecimals(t *testing.T) {
+	tests := []struct {
+		operator string
+		numbers  []string
+		expected string
+	}{
+		{"+", []string{"0.1", "0.2"}, "0.3"},
+		{"+", []string{"1.25", "-0.25"}, "1"},
+		{"+", []string{"1.50", "0"}, "1.5"},
+		{"-", []string{"5", "7.5"}, "-2.5"},
+		{"*", []string{"1.5", "-2.25"}, "-3.375"},
+		{"*", []string{"2.5", "4"}, "10"},
+		{"/", []string{"7.5", "2"}, "3.75"},
+		{"/", []string{"1.0", "8"}, "0.125"},
+		{"/", []string{"3", "0.0064"}, "468.75"},
+		{"/", []string{"1", "3.00"}, "0.33333333333333333333"},
+		{"/", []string{"-2", "3"}, "-0.66666666666666666666"},
+		{"/", []string{"1", "0.0000000000000000000000003"}, "3333333333333333333333333.3333333333333333333333333"},
+		{"/", []string{"1", "1024"}, "0.0009765625"},
+		{"/", []string{"-7", "2"}, "-3.5"},
+		{"%", []string{"7.5", "2"}, "1.5"},
+		{"^", []string{"1.5", "3"}, "3.375"},
+		{"^", []string{"-0.5", "2.0"}, "0.25"},
+		{"min", []string{"-1.5", "-1.25"}, "-1.5"},
+		{"max", []string{"2.50", "2.5", "1"}, "2.50"},
+		{"a*b+c", []string{"0.5", "4", "-1"}, "1"},
+		{"mean", []string{"1", "2"}, "1.5"},
+		{"mean", []string{"0.5", "1.5", "2"}, "1.33333333333333333333"},
+		{"mean", []string{"1.25", "2.5", "-0.5", "0.35"}, "0.9"},
+		{"a*0.5", []string{"3"}, "1.5"},
+	}
+
+	for _, tt := range tests {
+		var col Column
+		for _, s := range tt.numbers {
+			v, err := ParseValue(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			col.Numbers = append(col.Numbers, v)
+		}
+		if err := col.SetOperator(tt.operator); err != nil {
+			t.Fatal(err)
+		}
+		result, err := col.Calculate()
+		if err != nil {
+			t.Errorf("%v %s: unexpected error %v", tt.numbers, tt.operator, err)
+		} else if result.String() != tt.expected {
+			t.Errorf("%v %s = %s, want %s", tt.numbers, tt.operator, result, tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		operator string
+		numbers  []Value
+	}{
+		{"^", []Value{IntValue(2), {n: 5, scale: 1}}},       // 2 ^ 0.5
+		{"^", []Value{{n: 1, scale: 1}, IntValue(2000000)}}, // 0.1 ^ 2000000 has too many digits
+		{"/", []Value{IntValue(1), {n: 0, scale: 2}}},       // 1 / 0.00
+	}
+	for _, tt := range errorTests {
+		col := Column{Numbers: tt.numbers, Operator: tt.operator}
+		if result, err := col.Calculate(); err == nil {
+			t.Errorf("%v %s = %s, expected error", tt.numbers, tt.operator, result)
+		}
+	}
+}
+
+func TestParseFileSignedDecimals(t *testing.T) {
+	tests := []struct {
+		mode    string
+		content string
+		columns []string
+	}{
+		{
+			"original",
+			"-1.5  2.25 10\n 4   -0.75 -3\n *    +    -\n",
+			[]string{"-6", "1.5", "13"},
+		},
+		{
+			// Read vertically: "-1.5" and "2.0" in the first column, "-3" in the second
+			"aligned",
+			"-2  -\n1.  3\n.0   \n5    \n+   *\n",
+			[]string{"0.5", "-3"},
+		},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "decimals.txt")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		grid, err := parseFile(path, tt.mode)
+		if err != nil {
+			t.Fatalf("parseFile(%s) error = %v", tt.mode, err)
+		}
+		if len(grid.Columns) != len(tt.columns) {
+			t.Fatalf("parseFile(%s): expected %d columns, got %d", tt.mode, len(tt.columns), len(grid.Columns))
+		}
+		for i, expected := range tt.columns {
+			result, err := grid.Columns[i].Calculate()
+			if err != nil {
+				t.Errorf("%s column %d: unexpected error: %v", tt.mode, i+1, err)
+			} else if result.String() != expected {
+				t.Errorf("%s column %d = %s, want %s", tt.mode, i+1, result, expected)
+			}
+		}
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		content  string
+		expected []Diagnostic // only Row and Col are compared
+	}{
+		{"original well formed", "original", "1 2\n3 4\n+ *\n", nil},
+		{"original short and long rows", "original", "1 2 3\n\n4 5\n6 7 8 9\n+ * - max\n", []Diagnostic{{Row: 1, Col: 6}, {Row: 3, Col: 4}}},
+		{"original extra cells", "original", "1 2 3\n4 5\n+ *\n", []Diagnostic{{Row: 1, Col: 5}}},
+		{"original operator without numbers", "original", "1\n2\n+  *\n", []Diagnostic{{Row: 1, Col: 2}, {Row: 2, Col: 2}, {Row: 3, Col: 4}}},
+		{"original non-breaking space", "original", "1\u00a02\n4 5 6\n+ + +\n", []Diagnostic{{Row: 1, Col: 5}}},
+		{"original other white space", "original", "1\f2\v3\r\n4\t5 6\n+ + +\n", nil},
+		{"aligned well formed", "aligned", "123 328\n 45 64 \n+   *  \n", nil},
+		{"aligned left of first operator", "aligned", "x12  3\n 4   5\n +  *\n", []Diagnostic{{Row: 1, Col: 1}}},
+		{"aligned gap", "aligned", "12 3\n   4\n1 56\n+ * \n", []Diagnostic{{Row: 2, Col: 1}}},
+		{"aligned split number", "aligned", "1234 5\n 6   7\n+ *\n", []Diagnostic{{Row: 1, Col: 2}}},
+		{"aligned operator without numbers", "aligned", "12  3\n 4  5\n+   *  -\n", []Diagnostic{{Row: 3, Col: 8}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "worksheet.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			grid, err := parseFile(path, tt.mode)
+			if err != nil {
+				t.Fatalf("parseFile() error = %v", err)
+			}
+			if len(grid.Diagnostics) != len(tt.expected) {
+				t.Fatalf("Diagnostics = %v, want %d", grid.Diagnostics, len(tt.expected))
+			}
+			for i, d := range grid.Diagnostics {
+				if d.Row != tt.expected[i].Row || d.Col != tt.expected[i].Col {
+					t.Errorf("Diagnostics[%d] = %s, want row %d, col %d", i, d, tt.expected[i].Row, tt.expected[i].Col)
+				}
+			}
+		})
+	}
+
+	for _, mode := range []string{"original", "aligned"} {
+		grid, err := parseFile("example-data.txt", mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(grid.Diagnostics) > 0 {
+			t.Errorf("example-data.txt in %s mode: unexpected diagnostics %v", mode, grid.Diagnostics)
+		}
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	for _, line := range []string{"", "  ", "1 2  3", "\t1\f2\v3\r", "1\u00a02 \u30003", "  +  *  "} {
+		fields, starts := splitFields(line)
+		if !slices.Equal(fields, strings.Fields(line)) {
+			t.Errorf("splitFields(%q) = %q, strings.Fields gives %q", line, fields, strings.Fields(line))
+		}
+		for i, start := range starts {
+			if !strings.HasPrefix(line[start:], fields[i]) {
+				t.Errorf("splitFields(%q): field %q is not at %d", line, fields[i], start)
+			}
+		}
+	}
+
+	// Invalid numbers are reported at their position, whatever separates them
+	path := filepath.Join(t.TempDir(), "worksheet.txt")
+	if err := os.WriteFile(path, []byte("1\u00a0x 3\n4 5 6\n+ + +\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseFile(path, "original"); err == nil || !strings.Contains(err.Error(), "col 4") {
+		t.Errorf("parseFile() error = %v, want an invalid number at col 4", err)
 	}
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// operators maps each operator to the function that combines two values; a column
+// folds its numbers from left to right (top to bottom), so "-" gives a-b-c
+var operators = map[string]func(a, b Value) (Value, error){
+	"+":   Value.Add,
+	"-":   Value.Sub,
+	"*":   Value.Mul,
+	"/":   Value.Div,
+	"%":   Value.Mod,
+	"^":   Value.Pow,
+	"min": Value.Min,
+	"max": Value.Max,
+}
+
+// lookupOperator returns the function for an operator, or an error naming the valid ones
+func lookupOperator(op string) (func(a, b Value) (Value, error), error) {
+	if apply, ok := operators[op]; ok {
+		return apply, nil
+	}
+	names := make([]string, 0, len(operators))
+	for name := range operators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown operator %q (must be one of %s)", op, strings.Join(names, " "))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Output formats for Grid.Render
+const (
+	FormatColumns   = "columns"   // one "Column N: result" line per column
+	FormatEquations = "equations" // each column written out as an equation
+	FormatTable     = "table"     // the worksheet re-laid-out with right-aligned cells and results
+	FormatCSV       = "csv"       // one record per column
+)
+
+// Equation writes out the calculation of a column, such as "123 * 45 * 6 = 33210". A
+// formula is shown with the numbers it was applied to.
+func (c *Column) Equation(result Value) string {
+	numbers := make([]string, len(c.Numbers))
+	for i, n := range c.Numbers {
+		numbers[i] = n.String()
+	}
+
+	switch {
+	case c.Formula != nil:
+		return fmt.Sprintf("%s on %s = %s", c.Operator, strings.Join(numbers, ", "), result)
+	case len(numbers) == 0:
+		return fmt.Sprintf("no numbers = %s", result)
+	}
+	return fmt.Sprintf("%s = %s", strings.Join(numbers, " "+c.Operator+" "), result)
+}
+
+// Render calculates every column and writes them to w in the given format, returning the
+// total. The columns format is what CalculateTotal prints.
+func (g *Grid) Render(w io.Writer, format string) (Value, error) {
+	results := make([]Value, len(g.Columns))
+	var total Value
+	for i := range g.Columns {
+		var err error
+		if results[i], err = g.Columns[i].Calculate(); err != nil {
+			return Value{}, fmt.Errorf("column %d: %w", i+1, err)
+		}
+		total, _ = total.Add(results[i])
+	}
+
+	switch format {
+	case FormatColumns, FormatEquations:
+		for i := range g.Columns {
+			if format == FormatColumns {
+				fmt.Fprintf(w, "Column %d: %s\n", i+1, results[i])
+			} else {
+				fmt.Fprintf(w, "Column %d: %s\n", i+1, g.Columns[i].Equation(results[i]))
+			}
+		}
+	case FormatTable:
+		g.writeTable(w, results)
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"column", "operator", "numbers", "result"})
+		for i, col := range g.Columns {
+			numbers := make([]string, len(col.Numbers))
+			for j, n := range col.Numbers {
+				numbers[j] = n.String()
+			}
+			cw.Write([]string{strconv.Itoa(i + 1), col.Operator, strings.Join(numbers, " "), results[i].String()})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return Value{}, err
+		}
+	default:
+		return Value{}, fmt.Errorf("invalid format: %s (must be '%s', '%s', '%s' or '%s')", format, FormatColumns, FormatEquations, FormatTable, FormatCSV)
+	}
+	return total, nil
+}
+
+// writeTable writes the numbers of each column top to bottom in the order they were read,
+// then the operator, a rule and the result, every cell right-aligned to its column
+func (g *Grid) writeTable(w io.Writer, results []Value) {
+	rows := 0
+	for _, col := range g.Columns {
+		rows = max(rows, len(col.Numbers))
+	}
+
+	cells := make([][]string, rows+3)
+	widths := make([]int, len(g.Columns))
+	for i, col := range g.Columns {
+		for r := 0; r < rows; r++ {
+			cell := ""
+			if r < len(col.Numbers) {
+				cell = col.Numbers[r].String()
+			}
+			cells[r] = append(cells[r], cell)
+		}
+		cells[rows] = append(cells[rows], col.Operator)
+		cells[rows+2] = append(cells[rows+2], results[i].String())
+		for _, row := range cells {
+			if len(row) > i {
+				widths[i] = max(widths[i], len(row[i]))
+			}
+		}
+		cells[rows+1] = append(cells[rows+1], "")
+	}
+	for i := range cells[rows+1] {
+		cells[rows+1][i] = strings.Repeat("-", widths[i])
+	}
+
+	for _, row := range cells {
+		for i, cell := range row {
+			if i > 0 {
+				fmt.Fprint(w, " ")
+			}
+			fmt.Fprintf(w, "%*s", widths[i], cell)
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// maxResultBits bounds the size of a math/big result so a large power fails instead of
+// exhausting memory
+const maxResultBits = 1 << 20
+
+// divisionScale is the number of digits after the point kept for a quotient that never
+// terminates, such as 1 / 3; the digits beyond it are truncated
+const divisionScale = 20
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errNegativePower  = errors.New("negative exponent")
+	errFractionPower  = errors.New("exponent is not a whole number")
+	errTooLarge       = errors.New("result too large")
+)
+
+// Value is an exact decimal: an unscaled integer divided by 10^scale. The unscaled
+// integer is an int64 while the arithmetic fits and math/big once it overflows.
+type Value struct {
+	n     int64
+	big   *big.Int // set once the value no longer fits in int64
+	scale int      // digits after the decimal point
+}
+
+// IntValue returns n as a Value
+func IntValue(n int64) Value {
+	return Value{n: n}
+}
+
+// BigValue returns n as a Value, keeping it as an int64 when it fits
+func BigValue(n *big.Int) Value {
+	if n.IsInt64() {
+		return Value{n: n.Int64()}
+	}
+	return Value{big: n}
+}
+
+// ParseValue parses an integer or decimal with an optional sign, such as 42, -7 or 3.25.
+// The parsed value keeps its digits after the point as written, so 1.50 prints as 1.50.
+// Calculated results are normalized without trailing zeros, so 1.50 + 0 is 1.5.
+func ParseValue(s string) (Value, error) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 {
+		return Value{}, fmt.Errorf("invalid number %q", s)
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole+fraction == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return Value{}, fmt.Errorf("invalid number %q", s)
+	}
+
+	unscaled, ok := new(big.Int).SetString(whole+fraction, 10)
+	if !ok {
+		return Value{}, fmt.Errorf("invalid number %q", s)
+	}
+	if strings.HasPrefix(s, "-") {
+		unscaled.Neg(unscaled)
+	}
+	v := BigValue(unscaled)
+	v.scale = len(fraction)
+	return v, nil
+}
+
+// IsBig reports whether the value needed math/big
+func (v Value) IsBig() bool {
+	return v.big != nil
+}
+
+// Big returns the unscaled integer as a new big.Int
+func (v Value) Big() *big.Int {
+	if v.big != nil {
+		return new(big.Int).Set(v.big)
+	}
+	return big.NewInt(v.n)
+}
+
+func (v Value) String() string {
+	var s string
+	if v.big != nil {
+		s = v.big.String()
+	} else {
+		s = big.NewInt(v.n).String()
+	}
+	if v.scale == 0 {
+		return s
+	}
+
+	sign, digits := "", s
+	if s[0] == '-' {
+		sign, digits = "-", s[1:]
+	}
+	if len(digits) <= v.scale {
+		digits = strings.Repeat("0", v.scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-v.scale] + "." + digits[len(digits)-v.scale:]
+}
+
+// Cmp compares two values, returning -1, 0 or +1
+func (v Value) Cmp(w Value) int {
+	v, w, _ = align(v, w)
+	return v.cmp(w)
+}
+
+func (v Value) Add(w Value) (Value, error) {
+	v, w, scale := align(v, w)
+	return v.add(w).withScale(scale), nil
+}
+
+func (v Value) Sub(w Value) (Value, error) {
+	v, w, scale := align(v, w)
+	return v.sub(w).withScale(scale), nil
+}
+
+func (v Value) Mul(w Value) (Value, error) {
+	p := v.mul(w)
+	if p.big != nil && p.big.BitLen() > maxResultBits {
+		return Value{}, errTooLarge
+	}
+	return p.withScale(v.scale + w.scale), nil
+}
+
+// Div divides exactly, so 7 / 2 is 3.5 and 1.0 / 8 is 0.125. A quotient that never
+// terminates, such as 1 / 3, is truncated toward zero after divisionScale digits, or after
+// as many digits as the two values have if that is more.
+func (v Value) Div(w Value) (Value, error) {
+	if w.isZero() {
+		return Value{}, errDivisionByZero
+	}
+	v, w, scale := align(v, w)
+	digits, ok := quotientDigits(v, w)
+	if !ok {
+		digits = max(divisionScale, scale)
+	}
+	q := v.mul(pow10(digits)).quo(w)
+	if q.big != nil && q.big.BitLen() > maxResultBits {
+		return Value{}, errTooLarge
+	}
+	return q.withScale(digits), nil
+}
+
+// quotientDigits returns the number of digits after the point that the unscaled quotient
+// v / w needs, or false if it never terminates. It terminates when the divisor, reduced
+// by the common factors, has no prime factors but 2 and 5; w must not be zero.
+func quotientDigits(v, w Value) (int, bool) {
+	if v.rem(w).isZero() {
+		return 0, true
+	}
+	d := new(big.Int).Abs(w.Big())
+	d.Quo(d, new(big.Int).GCD(nil, nil, new(big.Int).Abs(v.Big()), d))
+	twos := int(d.TrailingZeroBits())
+	d.Rsh(d, uint(twos))
+	fives := 0
+	five, r := big.NewInt(5), new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(d, five, r)
+		if m.Sign() != 0 {
+			break
+		}
+		d = q
+		fives++
+	}
+	return max(twos, fives), d.IsInt64() && d.Int64() == 1
+}
+
+// Mod returns the remainder left by a whole quotient truncated toward zero, so 7.5 % 2 is
+// 1.5; it takes the sign of v
+func (v Value) Mod(w Value) (Value, error) {
+	if w.isZero() {
+		return Value{}, errDivisionByZero
+	}
+	v, w, scale := align(v, w)
+	return v.rem(w).withScale(scale), nil
+}
+
+// Pow raises v to the power w, which must be a whole number, by repeated squaring
+func (v Value) Pow(w Value) (Value, error) {
+	v, w = v.withScale(v.scale), w.withScale(w.scale)
+	if w.cmp(Value{}) < 0 {
+		return Value{}, errNegativePower
+	}
+	if w.scale != 0 {
+		return Value{}, errFractionPower
+	}
+	if v.scale > 0 {
+		if exp := w.Big(); !exp.IsInt64() || exp.Int64() > int64(maxResultBits/v.scale) {
+			return Value{}, errTooLarge
+		}
+	}
+	p, err := v.pow(w)
+	if err != nil {
+		return Value{}, err
+	}
+	if v.scale == 0 {
+		return p, nil
+	}
+	return p.withScale(v.scale * int(w.n)), nil
+}
+
+func (v Value) Min(w Value) (Value, error) {
+	if w.Cmp(v) < 0 {
+		return w, nil
+	}
+	return v, nil
+}
+
+func (v Value) Max(w Value) (Value, error) {
+	if w.Cmp(v) > 0 {
+		return w, nil
+	}
+	return v, nil
+}
+
+// align rescales two values to the larger of their scales
+func align(v, w Value) (Value, Value, int) {
+	scale := max(v.scale, w.scale)
+	return v.mul(pow10(scale - v.scale)), w.mul(pow10(scale - w.scale)), scale
+}
+
+// pow10 returns 10^n as an unscaled integer
+func pow10(n int) Value {
+	p, _ := IntValue(10).pow(IntValue(int64(n)))
+	return p
+}
+
+// withScale gives the unscaled integer v a scale, dropping trailing zeros after the point
+func (v Value) withScale(scale int) Value {
+	ten := IntValue(10)
+	for scale > 0 && v.rem(ten).isZero() {
+		v = v.quo(ten)
+		scale--
+	}
+	v.scale = scale
+	return v
+}
+
+// The methods below work on unscaled integers, ignoring scale
+
+func (v Value) isZero() bool {
+	return v.big == nil && v.n == 0
+}
+
+func (v Value) cmp(w Value) int {
+	if v.big == nil && w.big == nil {
+		switch {
+		case v.n < w.n:
+			return -1
+		case v.n > w.n:
+			return 1
+		}
+		return 0
+	}
+	return v.Big().Cmp(w.Big())
+}
+
+func (v Value) add(w Value) Value {
+	if v.big == nil && w.big == nil {
+		if s := v.n + w.n; (s > v.n) == (w.n > 0) {
+			return Value{n: s}
+		}
+	}
+	return BigValue(new(big.Int).Add(v.Big(), w.Big()))
+}
+
+func (v Value) sub(w Value) Value {
+	if v.big == nil && w.big == nil {
+		if d := v.n - w.n; (d < v.n) == (w.n > 0) {
+			return Value{n: d}
+		}
+	}
+	return BigValue(new(big.Int).Sub(v.Big(), w.Big()))
+}
+
+func (v Value) mul(w Value) Value {
+	if v.big == nil && w.big == nil {
+		if p, ok := mulInt64(v.n, w.n); ok {
+			return Value{n: p}
+		}
+	}
+	return BigValue(new(big.Int).Mul(v.Big(), w.Big()))
+}
+
+// quo divides, truncating toward zero; w must not be zero
+func (v Value) quo(w Value) Value {
+	if v.big == nil && w.big == nil && !(v.n == math.MinInt64 && w.n == -1) {
+		return Value{n: v.n / w.n}
+	}
+	return BigValue(new(big.Int).Quo(v.Big(), w.Big()))
+}
+
+// rem returns the remainder of truncated division; w must not be zero
+func (v Value) rem(w Value) Value {
+	if v.big == nil && w.big == nil {
+		if w.n == -1 {
+			return Value{}
+		}
+		return Value{n: v.n % w.n}
+	}
+	return BigValue(new(big.Int).Rem(v.Big(), w.Big()))
+}
+
+// pow raises v to the non-negative power w
+func (v Value) pow(w Value) (Value, error) {
+	if v.big == nil && w.big == nil {
+		if p, ok := powInt64(v.n, w.n); ok {
+			return Value{n: p}, nil
+		}
+	}
+
+	// Only 0, 1 and -1 stay small for huge exponents; anything else is too large
+	base := v.Big()
+	if base.CmpAbs(big.NewInt(1)) <= 0 {
+		if base.Sign() < 0 && w.Big().Bit(0) == 0 {
+			return IntValue(1), nil
+		}
+		return BigValue(base), nil
+	}
+	if exp := w.Big(); !exp.IsInt64() || exp.Int64() > maxResultBits/int64(base.BitLen()-1) {
+		return Value{}, errTooLarge
+	}
+	return BigValue(new(big.Int).Exp(base, w.Big(), nil)), nil
+}
+
+// mulInt64 multiplies, reporting false on overflow
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	p := a * b
+	if p/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return 0, false
+	}
+	return p, true
+}
+
+// powInt64 raises a to the non-negative power e, reporting false on overflow
+func powInt64(a, e int64) (int64, bool) {
+	result := int64(1)
+	for ; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			var ok bool
+			if result, ok = mulInt64(result, a); !ok {
+				return 0, false
+			}
+		}
+		if e > 1 {
+			var ok bool
+			if a, ok = mulInt64(a, a); !ok {
+				return 0, false
+			}
+		}
+	}
+	return result, true
+}
@@ -0,0 +1,111 @@
+package main
+
+// Direction is the way a beam is travelling
+type Direction int
+
+const (
+	Down Direction = iota
+	Left
+	Up
+	Right
+)
+
+func (d Direction) String() string {
+	return [...]string{"down", "left", "up", "right"}[d]
+}
+
+// step returns the position one cell from pos in direction d
+func (d Direction) step(pos Position) Position {
+	switch d {
+	case Down:
+		pos.Row++
+	case Up:
+		pos.Row--
+	case Left:
+		pos.Col--
+	case Right:
+		pos.Col++
+	}
+	return pos
+}
+
+// sides returns the directions perpendicular to d, left-hand (for a beam moving down,
+// the column to the left) first
+func (d Direction) sides() [2]Direction {
+	if d == Down || d == Up {
+		return [2]Direction{Left, Right}
+	}
+	return [2]Direction{Up, Down}
+}
+
+// vertical reports whether d is Down or Up
+func (d Direction) vertical() bool {
+	return d == Down || d == Up
+}
+
+// reflect returns the direction a beam moving in d leaves a mirror in
+func (d Direction) reflect(mirror Cell) Direction {
+	if mirror == Mirror {
+		// '/' turns down into left, left into down, up into right and right into up
+		return [...]Direction{Left, Down, Right, Up}[d]
+	}
+	// '\' turns down into right, right into down, up into left and left into up
+	return [...]Direction{Right, Up, Left, Down}[d]
+}
+
+// BeamState is a beam at a position, travelling in a direction. A beam that has left
+// through the bottom of the grid is at Row == Height.
+type BeamState struct {
+	Pos Position
+	Dir Direction
+}
+
+// exited reports whether the beam has left through the bottom of the grid
+func (g *Grid) exited(b BeamState) bool {
+	return b.Pos.Row == g.Height
+}
+
+// moves calls visit with each state a beam moves into in one step:
+//
+//	.      the beam moves into the cell
+//	^      the beam continues from the cells either side of the split, perpendicular to its
+//	       travel, if land allows a beam there
+//	v      the beam passes through, and also leaves the cell in both perpendicular directions
+//	/ \    the beam moves into the mirror and turns
+//	#      the beam is absorbed
+//
+// Any other cell, such as S, stops the beam. A beam moving down off the bottom of the grid
+// visits its exit state; one leaving any other edge is lost.
+func (g *Grid) moves(b BeamState, land func(Cell) bool, visit func(BeamState)) {
+	next := b.Dir.step(b.Pos)
+	if !g.IsInBounds(next) {
+		if b.Dir == Down && next.Row == g.Height {
+			visit(BeamState{Pos: next, Dir: Down})
+		}
+		return
+	}
+
+	switch cell := g.Get(next); cell {
+	case Empty:
+		visit(BeamState{Pos: next, Dir: b.Dir})
+	case Split:
+		for _, side := range b.Dir.sides() {
+			sidePos := side.step(next)
+			if g.IsInBounds(sidePos) && land(g.Get(sidePos)) {
+				visit(BeamState{Pos: sidePos, Dir: b.Dir})
+			}
+		}
+	case ThreeWay:
+		visit(BeamState{Pos: next, Dir: b.Dir})
+		for _, side := range b.Dir.sides() {
+			visit(BeamState{Pos: next, Dir: side})
+		}
+	case Mirror, BackMirror:
+		visit(BeamState{Pos: next, Dir: b.Dir.reflect(cell)})
+	}
+}
+
+// isSplitter reports whether c divides beams
+func isSplitter(c Cell) bool {
+	return c == Split || c == ThreeWay
+}
@@ -2,17 +2,25 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 )
 
 type Cell rune
 
 const (
-	Start Cell = 'S'
-	Empty Cell = '.'
-	Split Cell = '^'
-	Beam  Cell = '|'
+	Start      Cell = 'S'
+	Empty      Cell = '.'
+	Split      Cell = '^'
+	Beam       Cell = '|'
+	SideBeam   Cell = '-' // a beam moving left or right
+	CrossBeam  Cell = '+' // beams moving both ways
+	Mirror     Cell = '/'
+	BackMirror Cell = '\\'
+	Absorber   Cell = '#'
+	ThreeWay   Cell = 'v' // splits a beam three ways: straight on and to both sides
 )
 
 type Position struct {
@@ -86,120 +94,24 @@ func (g *Grid) Print() {
 	}
 }
 
+// ProcessBeams runs the beam simulation, printing each round. The grid is left unchanged.
 func (g *Grid) ProcessBeams() int {
-	start := g.FindStart()
-	if start == nil {
+	if g.FindStart() == nil {
 		fmt.Println("No start position found")
 		return 0
 	}
 	
-	round := 0
-	totalSplits := 0
-	activeBeams := []Position{{Row: start.Row, Col: start.Col}}
-	
-	for len(activeBeams) > 0 {
-		round++
-		roundSplits := 0
-		
-		var nextBeams []Position
-		
-		for _, beam := range activeBeams {
-			nextRow := beam.Row + 1
-			
-			if nextRow >= g.Height {
-				continue
-			}
-			
-			nextPos := Position{Row: nextRow, Col: beam.Col}
-			targetCell := g.Get(nextPos)
-			
-			if targetCell == Split {
-				roundSplits++
-				leftCol := beam.Col - 1
-				rightCol := beam.Col + 1
-				
-				if leftCol >= 0 {
-					leftPos := Position{Row: nextRow, Col: leftCol}
-					if g.Get(leftPos) == Empty {
-						g.Set(leftPos, Beam)
-						nextBeams = append(nextBeams, leftPos)
-					}
-				}
-				
-				if rightCol < g.Width {
-					rightPos := Position{Row: nextRow, Col: rightCol}
-					if g.Get(rightPos) == Empty {
-						g.Set(rightPos, Beam)
-						nextBeams = append(nextBeams, rightPos)
-					}
-				}
-			} else if targetCell == Empty {
-				g.Set(nextPos, Beam)
-				nextBeams = append(nextBeams, nextPos)
-			}
-		}
+	s := g.NewSimulation()
+	for !s.Done() {
+		frame := s.Step()
 		
-		totalSplits += roundSplits
-		
-		fmt.Printf("\n=== Round %d ===\n", round)
-		fmt.Printf("Splits this round: %d\n", roundSplits)
-		fmt.Printf("Total splits: %d\n", totalSplits)
-		activeBeams = nextBeams
-		g.Print()
-	}
-	
-	return round
-}
-
-func (g *Grid) CountPaths() int {
-	start := g.FindStart()
-	if start == nil {
-		return 0
+		fmt.Printf("\n=== Round %d ===\n", frame.Round)
+		fmt.Printf("Splits this round: %d\n", frame.RoundSplits)
+		fmt.Printf("Total splits: %d\n", frame.TotalSplits)
+		fmt.Print(frame)
 	}
 	
-	memo := make(map[Position]int)
-	return g.countPathsFrom(start.Row, start.Col, memo)
-}
-
-func (g *Grid) countPathsFrom(row, col int, memo map[Position]int) int {
-	pos := Position{Row: row, Col: col}
-	
-	if cached, exists := memo[pos]; exists {
-		return cached
-	}
-	
-	nextRow := row + 1
-	
-	if nextRow >= g.Height {
-		return 1
-	}
-	
-	nextCell := g.Get(Position{Row: nextRow, Col: col})
-	
-	var result int
-	
-	if nextCell == Split {
-		leftCol := col - 1
-		if leftCol >= 0 {
-			leftCell := g.Get(Position{Row: nextRow, Col: leftCol})
-			if leftCell == Empty || leftCell == Split {
-				result += g.countPathsFrom(nextRow, leftCol, memo)
-			}
-		}
-		
-		rightCol := col + 1
-		if rightCol < g.Width {
-			rightCell := g.Get(Position{Row: nextRow, Col: rightCol})
-			if rightCell == Empty || rightCell == Split {
-				result += g.countPathsFrom(nextRow, rightCol, memo)
-			}
-		}
-	} else if nextCell == Empty || nextCell == Split {
-		result = g.countPathsFrom(nextRow, col, memo)
-	}
-	
-	memo[pos] = result
-	return result
+	return s.Round
 }
 
 func parseFile(filepath string) (*Grid, error) {
@@ -226,8 +138,14 @@ func parseFile(filepath string) (*Grid, error) {
 
 func main() {
 	if len(os.Args) < 3 {
-		fmt.Println("Usage: day7 <mode> <filepath>")
+		fmt.Println("Usage: day7 <mode> <filepath> [options]")
 		fmt.Println("  mode: 'splits' or 'paths'")
+		fmt.Println("  options (splits mode):")
+		fmt.Println("    -text <file>  write every round as text")
+		fmt.Println("    -svg <file>   write an SVG with one layer per round")
+		fmt.Println("    -gif <file>   write an animated GIF of the rounds")
+		fmt.Println("  options (paths mode):")
+		fmt.Println("    -report table|histogram  break the paths down by exit column and splitter")
 		os.Exit(1)
 	}
 	
@@ -239,6 +157,23 @@ func main() {
 		os.Exit(1)
 	}
 	
+	fs := flag.NewFlagSet("day7", flag.ContinueOnError)
+	textFile := fs.String("text", "", "text output file for the rounds")
+	svgFile := fs.String("svg", "", "layered SVG output file")
+	gifFile := fs.String("gif", "", "animated GIF output file")
+	report := fs.String("report", "", "exit distribution report: table or histogram")
+	if err := fs.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected argument %q\n", fs.Arg(0))
+		os.Exit(1)
+	}
+	if *report != "" && *report != ReportTable && *report != ReportHistogram {
+		fmt.Fprintf(os.Stderr, "Invalid report: %s (must be 'table' or 'histogram')\n", *report)
+		os.Exit(1)
+	}
+	
 	grid, err := parseFile(filepath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing file: %v\n", err)
@@ -252,8 +187,48 @@ func main() {
 		rounds := grid.ProcessBeams()
 		
 		fmt.Printf("\n=== Finished after %d rounds ===\n", rounds)
+		
+		exports := []struct {
+			path  string
+			write func(io.Writer, *Grid, []Frame) error
+		}{
+			{*textFile, WriteFramesText},
+			{*svgFile, WriteFramesSVG},
+			{*gifFile, WriteFramesGIF},
+		}
+		var frames []Frame
+		for _, export := range exports {
+			if export.path == "" {
+				continue
+			}
+			if frames == nil {
+				frames = grid.Frames()
+			}
+			if err := writeFramesFile(export.path, export.write, grid, frames); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", export.path, err)
+				os.Exit(1)
+			}
+			fmt.Printf("Frames saved to: %s\n", export.path)
+		}
 	} else {
-		paths := grid.CountPaths()
-		fmt.Printf("Total paths from S to bottom: %d\n", paths)
+		paths, err := grid.CountPaths()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error counting paths: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Total paths from S to bottom: %s\n", paths)
+		
+		if *report != "" {
+			exits, err := grid.ExitReport()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error counting exits: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println()
+			if err := exits.Render(os.Stdout, *report); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing report: %v\n", err)
+				os.Exit(1)
+			}
+		}
 	}
 }
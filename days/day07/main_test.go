@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"image/gif"
+	"math/big"
+	"math/rand"
 	"os"
 	"strings"
 	"testing"
@@ -60,20 +64,20 @@ func TestSimpleBeamMovement(t *testing.T) {
 	
 	grid := NewGrid(lines)
 	
-	paths := grid.CountPaths()
-	if paths != 1 {
+	paths := mustCountPaths(t, grid)
+	if paths.Cmp(big.NewInt(1)) != 0 {
 		t.Errorf("Expected 1 path, got %d", paths)
 	}
 	
-	grid = NewGrid(lines)
-	grid.ProcessBeams()
+	frames := grid.Frames()
+	final := frames[len(frames)-1].Cells
 	
-	if grid.Cells[1][2] != Beam {
-		t.Errorf("Expected beam at [1][2], got %c", grid.Cells[1][2])
+	if final[1][2] != Beam {
+		t.Errorf("Expected beam at [1][2], got %c", final[1][2])
 	}
 	
-	if grid.Cells[2][2] != Beam {
-		t.Errorf("Expected beam at [2][2], got %c", grid.Cells[2][2])
+	if final[2][2] != Beam {
+		t.Errorf("Expected beam at [2][2], got %c", final[2][2])
 	}
 }
 
@@ -87,32 +91,32 @@ func TestBeamSplit(t *testing.T) {
 	
 	grid := NewGrid(lines)
 	
-	paths := grid.CountPaths()
-	if paths != 2 {
+	paths := mustCountPaths(t, grid)
+	if paths.Cmp(big.NewInt(2)) != 0 {
 		t.Errorf("Expected 2 paths, got %d", paths)
 	}
 	
-	grid = NewGrid(lines)
-	grid.ProcessBeams()
+	frames := grid.Frames()
+	final := frames[len(frames)-1].Cells
 	
-	if grid.Cells[1][2] != Beam {
-		t.Errorf("Expected beam at [1][2], got %c", grid.Cells[1][2])
+	if final[1][2] != Beam {
+		t.Errorf("Expected beam at [1][2], got %c", final[1][2])
 	}
 	
-	if grid.Cells[2][1] != Beam {
-		t.Errorf("Expected beam at [2][1] (left of split), got %c", grid.Cells[2][1])
+	if final[2][1] != Beam {
+		t.Errorf("Expected beam at [2][1] (left of split), got %c", final[2][1])
 	}
 	
-	if grid.Cells[2][3] != Beam {
-		t.Errorf("Expected beam at [2][3] (right of split), got %c", grid.Cells[2][3])
+	if final[2][3] != Beam {
+		t.Errorf("Expected beam at [2][3] (right of split), got %c", final[2][3])
 	}
 	
-	if grid.Cells[3][1] != Beam {
-		t.Errorf("Expected beam at [3][1], got %c", grid.Cells[3][1])
+	if final[3][1] != Beam {
+		t.Errorf("Expected beam at [3][1], got %c", final[3][1])
 	}
 	
-	if grid.Cells[3][3] != Beam {
-		t.Errorf("Expected beam at [3][3], got %c", grid.Cells[3][3])
+	if final[3][3] != Beam {
+		t.Errorf("Expected beam at [3][3], got %c", final[3][3])
 	}
 }
 
@@ -126,17 +130,13 @@ func TestExampleData(t *testing.T) {
 		t.Fatalf("Failed to parse example-data-1.txt: %v", err)
 	}
 	
-	paths := grid.CountPaths()
-	if paths != 40 {
+	paths := mustCountPaths(t, grid)
+	if paths.Cmp(big.NewInt(40)) != 0 {
 		t.Errorf("Expected 40 paths, got %d", paths)
 	}
 	
-	grid, err = parseFile("example-data-1.txt")
-	if err != nil {
-		t.Fatalf("Failed to parse example-data-1.txt: %v", err)
-	}
-	
-	grid.ProcessBeams()
+	frames := grid.Frames()
+	final := frames[len(frames)-1].Cells
 	
 	expectedFile, err := os.ReadFile("example-data-2.txt")
 	if err != nil {
@@ -153,7 +153,7 @@ func TestExampleData(t *testing.T) {
 	for i := 0; i < grid.Height; i++ {
 		var actual strings.Builder
 		for j := 0; j < grid.Width; j++ {
-			actual.WriteRune(rune(grid.Cells[i][j]))
+			actual.WriteRune(rune(final[i][j]))
 		}
 		
 		if actual.String() != expectedLines[i] {
@@ -173,24 +173,24 @@ func TestMultipleSplits(t *testing.T) {
 	
 	grid := NewGrid(lines)
 	
-	paths := grid.CountPaths()
-	if paths != 1 {
+	paths := mustCountPaths(t, grid)
+	if paths.Cmp(big.NewInt(1)) != 0 {
 		t.Errorf("Expected 1 path, got %d", paths)
 	}
 	
-	grid = NewGrid(lines)
-	grid.ProcessBeams()
+	frames := grid.Frames()
+	final := frames[len(frames)-1].Cells
 	
-	if grid.Cells[1][3] != Beam {
-		t.Errorf("Expected beam at [1][3], got %c", grid.Cells[1][3])
+	if final[1][3] != Beam {
+		t.Errorf("Expected beam at [1][3], got %c", final[1][3])
 	}
 	
-	if grid.Cells[2][3] != Beam {
-		t.Errorf("Expected beam at [2][3] (between splits), got %c", grid.Cells[2][3])
+	if final[2][3] != Beam {
+		t.Errorf("Expected beam at [2][3] (between splits), got %c", final[2][3])
 	}
 	
-	if grid.Cells[3][3] != Beam {
-		t.Errorf("Expected beam at [3][3] (continued down), got %c", grid.Cells[3][3])
+	if final[3][3] != Beam {
+		t.Errorf("Expected beam at [3][3] (continued down), got %c", final[3][3])
 	}
 }
 
@@ -204,24 +204,24 @@ func TestEdgeSplit(t *testing.T) {
 	
 	grid := NewGrid(lines)
 	
-	paths := grid.CountPaths()
-	if paths != 1 {
+	paths := mustCountPaths(t, grid)
+	if paths.Cmp(big.NewInt(1)) != 0 {
 		t.Errorf("Expected 1 path (split at edge, only right side), got %d", paths)
 	}
 	
-	grid = NewGrid(lines)
-	grid.ProcessBeams()
+	frames := grid.Frames()
+	final := frames[len(frames)-1].Cells
 	
-	if grid.Cells[1][0] != Beam {
-		t.Errorf("Expected beam at [1][0], got %c", grid.Cells[1][0])
+	if final[1][0] != Beam {
+		t.Errorf("Expected beam at [1][0], got %c", final[1][0])
 	}
 	
-	if grid.Cells[2][1] != Beam {
-		t.Errorf("Expected beam at [2][1] (right of edge split), got %c", grid.Cells[2][1])
+	if final[2][1] != Beam {
+		t.Errorf("Expected beam at [2][1] (right of edge split), got %c", final[2][1])
 	}
 	
-	if grid.Cells[3][1] != Beam {
-		t.Errorf("Expected beam at [3][1], got %c", grid.Cells[3][1])
+	if final[3][1] != Beam {
+		t.Errorf("Expected beam at [3][1], got %c", final[3][1])
 	}
 }
 
@@ -267,10 +267,421 @@ func TestCountPathsSimple(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			grid := NewGrid(tt.lines)
-			paths := grid.CountPaths()
-			if paths != tt.expected {
+			paths := mustCountPaths(t, grid)
+			if paths.Cmp(big.NewInt(int64(tt.expected))) != 0 {
 				t.Errorf("Expected %d paths, got %d", tt.expected, paths)
 			}
 		})
 	}
 }
+
+func TestProcessBeamsLeavesGridUnchanged(t *testing.T) {
+	grid, err := parseFile("example-data-1.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse example-data-1.txt: %v", err)
+	}
+	input, err := parseFile("example-data-1.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse example-data-1.txt: %v", err)
+	}
+
+	if rounds := grid.ProcessBeams(); rounds != 16 {
+		t.Errorf("Expected 16 rounds, got %d", rounds)
+	}
+	for i := range grid.Cells {
+		if string(grid.Cells[i]) != string(input.Cells[i]) {
+			t.Errorf("Row %d changed by ProcessBeams: %s", i, string(grid.Cells[i]))
+		}
+	}
+
+	// The same grid can be simulated again and have its paths counted
+	if paths := mustCountPaths(t, grid); paths.Cmp(big.NewInt(40)) != 0 {
+		t.Errorf("Expected 40 paths after ProcessBeams, got %d", paths)
+	}
+	if rounds := grid.ProcessBeams(); rounds != 16 {
+		t.Errorf("Expected 16 rounds on the second run, got %d", rounds)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	grid, err := parseFile("example-data-1.txt")
+	if err != nil {
+		t.Fatalf("Failed to parse example-data-1.txt: %v", err)
+	}
+
+	frames := grid.Frames()
+	if len(frames) != 17 {
+		t.Fatalf("Expected 17 frames (input and 16 rounds), got %d", len(frames))
+	}
+	if frames[0].Round != 0 || frames[0].Cells[1][7] != Empty {
+		t.Errorf("First frame should be the input grid, got round %d:\n%s", frames[0].Round, frames[0])
+	}
+	if frames[1].Cells[1][7] != Beam || len(frames[1].Added) != 1 {
+		t.Errorf("Round 1 should add one beam below the start, got %v", frames[1].Added)
+	}
+	if frames[2].RoundSplits != 1 || len(frames[2].Added) != 2 {
+		t.Errorf("Round 2: expected 1 split adding 2 beams, got %d splits and %v", frames[2].RoundSplits, frames[2].Added)
+	}
+
+	last := frames[len(frames)-1]
+	if last.TotalSplits != 21 || len(last.Added) != 0 {
+		t.Errorf("Last frame: expected 21 total splits and no beams added, got %d and %v", last.TotalSplits, last.Added)
+	}
+}
+
+func TestWriteFrames(t *testing.T) {
+	grid := NewGrid([]string{
+		"..S..",
+		".....",
+		"..^..",
+		".....",
+	})
+	frames := grid.Frames()
+
+	var text bytes.Buffer
+	if err := WriteFramesText(&text, grid, frames); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"=== Initial State ===\n..S..\n", "=== Round 2 ===\nSplits this round: 1\n", ".|.|.\n", "Finished after 4 rounds"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Text frames missing %q:\n%s", want, text.String())
+		}
+	}
+
+	var svg bytes.Buffer
+	if err := WriteFramesSVG(&svg, grid, frames); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(svg.String(), `<g id="round-4"`) || strings.Contains(svg.String(), `<g id="round-5"`) {
+		t.Errorf("SVG should have one layer per round:\n%s", svg.String())
+	}
+
+	var buf bytes.Buffer
+	if err := WriteFramesGIF(&buf, grid, frames); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("Decoding GIF: %v", err)
+	}
+	if len(anim.Image) != len(frames) {
+		t.Errorf("Expected %d GIF frames, got %d", len(frames), len(anim.Image))
+	}
+}
+
+// countPathsRecursive is the original memoized recursion, kept as a reference for CountPaths
+func countPathsRecursive(g *Grid, row, col int, memo map[Position]int) int {
+	pos := Position{Row: row, Col: col}
+	if cached, exists := memo[pos]; exists {
+		return cached
+	}
+	nextRow := row + 1
+	if nextRow >= g.Height {
+		return 1
+	}
+
+	var result int
+	nextCell := g.Get(Position{Row: nextRow, Col: col})
+	if nextCell == Split {
+		for _, sideCol := range []int{col - 1, col + 1} {
+			if sideCol >= 0 && sideCol < g.Width {
+				if side := g.Get(Position{Row: nextRow, Col: sideCol}); side == Empty || side == Split {
+					result += countPathsRecursive(g, nextRow, sideCol, memo)
+				}
+			}
+		}
+	} else if nextCell == Empty {
+		result = countPathsRecursive(g, nextRow, col, memo)
+	}
+	memo[pos] = result
+	return result
+}
+
+func TestCountPathsMatchesRecursion(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 200; i++ {
+		height, width := 2+rng.Intn(12), 1+rng.Intn(9)
+		lines := make([]string, height)
+		for row := range lines {
+			line := []byte(strings.Repeat(".", width))
+			for col := range line {
+				if rng.Intn(3) == 0 {
+					line[col] = '^'
+				}
+			}
+			lines[row] = string(line)
+		}
+		startRow, startCol := rng.Intn(height), rng.Intn(width)
+		lines[startRow] = lines[startRow][:startCol] + "S" + lines[startRow][startCol+1:]
+
+		grid := NewGrid(lines)
+		expected := countPathsRecursive(grid, startRow, startCol, make(map[Position]int))
+		if paths := mustCountPaths(t, grid); paths.Cmp(big.NewInt(int64(expected))) != 0 {
+			t.Fatalf("CountPaths() = %s, recursion gives %d for\n%s", paths, expected, strings.Join(lines, "\n"))
+		}
+	}
+
+	for _, file := range []string{"example-data-1.txt", "complex-test.txt", "simple-test.txt"} {
+		grid, err := parseFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start := grid.FindStart()
+		expected := countPathsRecursive(grid, start.Row, start.Col, make(map[Position]int))
+		if paths := mustCountPaths(t, grid); paths.Cmp(big.NewInt(int64(expected))) != 0 {
+			t.Errorf("%s: CountPaths() = %s, recursion gives %d", file, paths, expected)
+		}
+	}
+}
+
+func TestCountPathsLarge(t *testing.T) {
+	// A triangle of splitters like the example, 100 levels deep: every level doubles
+	// the paths, so there are 2^100
+	const levels = 100
+	width := 2*levels + 3
+	center := width / 2
+	lines := []string{strings.Repeat(".", center) + "S" + strings.Repeat(".", center)}
+	for level := 0; level < levels; level++ {
+		line := []byte(strings.Repeat(".", width))
+		for col := center - level; col <= center+level; col += 2 {
+			line[col] = '^'
+		}
+		lines = append(lines, strings.Repeat(".", width), string(line))
+	}
+	lines = append(lines, strings.Repeat(".", width))
+
+	expected := new(big.Int).Lsh(big.NewInt(1), levels)
+	if paths := mustCountPaths(t, NewGrid(lines)); paths.Cmp(expected) != 0 {
+		t.Errorf("Expected 2^%d = %s paths, got %s", levels, expected, paths)
+	}
+
+	// A tall grid needs no recursion
+	tall := make([]string, 200000)
+	for i := range tall {
+		tall[i] = "."
+	}
+	tall[0] = "S"
+	if paths := mustCountPaths(t, NewGrid(tall)); paths.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("Expected 1 path through a tall grid, got %s", paths)
+	}
+}
+
+func mustCountPaths(t *testing.T, grid *Grid) *big.Int {
+	t.Helper()
+	paths, err := grid.CountPaths()
+	if err != nil {
+		t.Fatalf("CountPaths() error = %v", err)
+	}
+	return paths
+}
+
+func TestOpticalComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		paths int64 // -1 when counting should fail with a loop
+		final []string
+	}{
+		{
+			name:  "mirror into absorber",
+			lines: []string{".S...", ".\\.#.", "....."},
+			paths: 0,
+			final: []string{".S...", ".\\-#.", "....."},
+		},
+		{
+			name:  "mirrors turn the beam sideways and back down",
+			lines: []string{"S....", "\\..\\.", "....."},
+			paths: 1,
+			final: []string{"S....", "\\--\\.", "...|."},
+		},
+		{
+			name:  "three-way splitter",
+			lines: []string{"..S..", ".....", "/.v.\\", "....."},
+			paths: 3,
+			final: []string{"..S..", "..|..", "/-v-\\", "|.|.|"},
+		},
+		{
+			name:  "sideways beam around a split",
+			lines: []string{"S.....", "\\..^..", "......"},
+			paths: 0,
+			final: []string{"S..---", "\\--^..", "...---"},
+		},
+		{
+			name:  "loop that leaks to the bottom",
+			lines: []string{"..S..", "/.v.\\", ".....", "\\.../", "....."},
+			paths: -1,
+			final: []string{"..S..", "/-v-\\", "|.|.|", "\\-+-/", "..|.."},
+		},
+		{
+			name:  "loop that never reaches the bottom",
+			lines: []string{"..S..", "/.v.\\", ".....", "\\.../", "..#.."},
+			paths: 0,
+			final: []string{"..S..", "/-v-\\", "|.|.|", "\\-+-/", "..#.."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grid := NewGrid(tt.lines)
+
+			paths, err := grid.CountPaths()
+			switch {
+			case tt.paths < 0 && err == nil:
+				t.Errorf("CountPaths() = %s, expected a loop error", paths)
+			case tt.paths >= 0 && err != nil:
+				t.Errorf("CountPaths() error = %v", err)
+			case tt.paths >= 0 && paths.Cmp(big.NewInt(tt.paths)) != 0:
+				t.Errorf("CountPaths() = %s, want %d", paths, tt.paths)
+			}
+
+			frames := grid.Frames()
+			got := strings.TrimSpace(frames[len(frames)-1].String())
+			if want := strings.Join(tt.final, "\n"); got != want {
+				t.Errorf("Final frame:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestExitReport(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		exits    []int64
+		hits     []int64 // per splitter, top to bottom and left to right
+		hasError bool
+	}{
+		{
+			name:  "split",
+			lines: []string{".S.", ".^.", "..."},
+			exits: []int64{1, 0, 1},
+			hits:  []int64{2},
+		},
+		{
+			name:  "sideways beams lost off the edges",
+			lines: []string{"..S..", "..v..", "....."},
+			exits: []int64{0, 0, 1, 0, 0},
+			hits:  []int64{1},
+		},
+		{
+			name:  "three-way split with mirrors",
+			lines: []string{"..S..", "/.v.\\", "....."},
+			exits: []int64{1, 0, 1, 0, 1},
+			hits:  []int64{3},
+		},
+		{
+			name:  "unused splitter",
+			lines: []string{"S..", "...", "..^"},
+			exits: []int64{1, 0, 0},
+			hits:  []int64{0},
+		},
+		{
+			name:  "no start",
+			lines: []string{"...", ".^."},
+			exits: []int64{0, 0, 0},
+			hits:  []int64{0},
+		},
+		{
+			name:     "loop",
+			lines:    []string{"..S..", "/.v.\\", ".....", "\\.../", "....."},
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := NewGrid(tt.lines).ExitReport()
+			if tt.hasError {
+				if err == nil {
+					t.Fatal("Expected an error for a loop")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExitReport() error = %v", err)
+			}
+			if len(report.Exits) != len(tt.exits) {
+				t.Fatalf("Expected %d exit columns, got %d", len(tt.exits), len(report.Exits))
+			}
+			for col, expected := range tt.exits {
+				if report.Exits[col].Cmp(big.NewInt(expected)) != 0 {
+					t.Errorf("Column %d: expected %d exits, got %s", col+1, expected, report.Exits[col])
+				}
+			}
+			if len(report.Splitters) != len(tt.hits) {
+				t.Fatalf("Expected %d splitters, got %d", len(tt.hits), len(report.Splitters))
+			}
+			for i, expected := range tt.hits {
+				if report.Splitters[i].Hits.Cmp(big.NewInt(expected)) != 0 {
+					t.Errorf("Splitter at %+v: expected %d hits, got %s", report.Splitters[i].Pos, expected, report.Splitters[i].Hits)
+				}
+			}
+		})
+	}
+}
+
+func TestExitReportExampleData(t *testing.T) {
+	grid, err := parseFile("example-data-1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := grid.ExitReport()
+	if err != nil {
+		t.Fatalf("ExitReport() error = %v", err)
+	}
+
+	// Every path leaves through exactly one column
+	sum := new(big.Int)
+	for _, exits := range report.Exits {
+		sum.Add(sum, exits)
+	}
+	if report.Total.Cmp(big.NewInt(40)) != 0 || sum.Cmp(report.Total) != 0 {
+		t.Errorf("Expected 40 paths in total and across the columns, got %s and %s", report.Total, sum)
+	}
+	if report.Exits[6].Cmp(big.NewInt(11)) != 0 {
+		t.Errorf("Expected 11 paths out of column 7, got %s", report.Exits[6])
+	}
+
+	// Every path passes the first splitter
+	first := report.Splitters[0]
+	if first.Pos != (Position{Row: 2, Col: 7}) || first.Hits.Cmp(report.Total) != 0 {
+		t.Errorf("Expected every path through the splitter at row 3, column 8, got %s at %+v", first.Hits, first.Pos)
+	}
+}
+
+func TestExitReportRender(t *testing.T) {
+	report, err := NewGrid([]string{"..S..", "/.v..", "....."}).ExitReport()
+	if err != nil {
+		t.Fatalf("ExitReport() error = %v", err)
+	}
+
+	var table bytes.Buffer
+	if err := report.Render(&table, ReportTable); err != nil {
+		t.Fatal(err)
+	}
+	expectedTable := "Exits by column:\n" +
+		"  column 1  1   50.00%\n" +
+		"  column 2  0    0.00%\n" +
+		"  column 3  1   50.00%\n" +
+		"  column 4  0    0.00%\n" +
+		"  column 5  0    0.00%\n" +
+		"\nSplitter hits:\n" +
+		"  row 2, column 3  2  100.00%\n"
+	if table.String() != expectedTable {
+		t.Errorf("Table:\n%s\nexpected:\n%s", table.String(), expectedTable)
+	}
+
+	var histogram bytes.Buffer
+	if err := report.Render(&histogram, ReportHistogram); err != nil {
+		t.Fatal(err)
+	}
+	full := strings.Repeat("#", histogramWidth)
+	if !strings.Contains(histogram.String(), "  column 1 |"+full+" 1\n") ||
+		!strings.Contains(histogram.String(), "  column 2 |"+strings.Repeat(" ", histogramWidth)+" 0\n") {
+		t.Errorf("Histogram bars are not scaled to the largest count:\n%s", histogram.String())
+	}
+
+	if err := report.Render(&histogram, "pie"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
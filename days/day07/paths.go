@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// stateGraph is every beam state reachable from S with the states each one moves into.
+// Exit states (below the bottom row) have no successors.
+type stateGraph struct {
+	states []BeamState
+	index  map[BeamState]int
+	next   [][]int
+}
+
+func (sg *stateGraph) add(b BeamState) int {
+	if i, ok := sg.index[b]; ok {
+		return i
+	}
+	sg.index[b] = len(sg.states)
+	sg.states = append(sg.states, b)
+	sg.next = append(sg.next, nil)
+	return len(sg.states) - 1
+}
+
+// pathLand is where a path may continue beside a split: an empty cell, or another split,
+// which the beam then continues through as if it were empty
+func pathLand(c Cell) bool {
+	return c == Empty || c == Split
+}
+
+// reachableStates finds the states reachable from start, breadth first
+func (g *Grid) reachableStates(start BeamState) *stateGraph {
+	sg := &stateGraph{index: make(map[BeamState]int)}
+	sg.add(start)
+	for i := 0; i < len(sg.states); i++ {
+		if g.exited(sg.states[i]) {
+			continue
+		}
+		g.moves(sg.states[i], pathLand, func(b BeamState) {
+			sg.next[i] = append(sg.next[i], sg.add(b))
+		})
+	}
+	return sg
+}
+
+// order returns the states that can reach an exit, each after every state it moves into,
+// so counts can be built up from the exits. It fails if such states form a loop, which
+// gives infinitely many paths; loops that never reach an exit are ignored.
+func (g *Grid) order(sg *stateGraph) ([]int, error) {
+	// Work back from the exits to find the states that can reach one
+	prev := make([][]int, len(sg.states))
+	var queue []int
+	for i, next := range sg.next {
+		for _, j := range next {
+			prev[j] = append(prev[j], i)
+		}
+		if g.exited(sg.states[i]) {
+			queue = append(queue, i)
+		}
+	}
+	canExit := make([]bool, len(sg.states))
+	for _, i := range queue {
+		canExit[i] = true
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range prev[i] {
+			if !canExit[j] {
+				canExit[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	// Depth-first from S with an explicit stack; a state still on the stack when it is
+	// reached again is part of a loop
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	mark := make([]int, len(sg.states))
+	var order []int
+	if !canExit[0] {
+		return order, nil
+	}
+	type entry struct{ state, child int }
+	stack := []entry{{state: 0}}
+	mark[0] = onStack
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.child == len(sg.next[top.state]) {
+			mark[top.state] = done
+			order = append(order, top.state)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		child := sg.next[top.state][top.child]
+		top.child++
+		switch {
+		case !canExit[child] || mark[child] == done:
+		case mark[child] == onStack:
+			b := sg.states[child]
+			return nil, fmt.Errorf("beam loops through row %d, column %d moving %s, so there are infinitely many paths", b.Pos.Row+1, b.Pos.Col+1, b.Dir)
+		default:
+			mark[child] = onStack
+			stack = append(stack, entry{state: child})
+		}
+	}
+	return order, nil
+}
+
+// pathCounts builds the state graph from S and counts the paths from each state to the
+// bottom, returning the graph, the states in the order they were counted and the counts.
+// States that cannot reach an exit count zero. A grid without a start gives a nil graph.
+func (g *Grid) pathCounts() (*stateGraph, []int, []big.Int, error) {
+	start := g.FindStart()
+	if start == nil {
+		return nil, nil, nil, nil
+	}
+
+	sg := g.reachableStates(BeamState{Pos: *start, Dir: Down})
+	order, err := g.order(sg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	paths := make([]big.Int, len(sg.states))
+	for _, i := range order {
+		if g.exited(sg.states[i]) {
+			paths[i].SetInt64(1)
+			continue
+		}
+		for _, j := range sg.next[i] {
+			paths[i].Add(&paths[i], &paths[j])
+		}
+	}
+	return sg, order, paths, nil
+}
+
+// CountPaths counts the paths from S to the bottom of the grid, following the beam through
+// splits, mirrors and sideways moves. Counts are math/big, since the number of paths can
+// double at every row of splitters. A loop that a path can go around any number of times
+// before leaving the grid is an error.
+func (g *Grid) CountPaths() (*big.Int, error) {
+	sg, _, paths, err := g.pathCounts()
+	if err != nil {
+		return nil, err
+	}
+	if sg == nil {
+		return new(big.Int), nil
+	}
+	return new(big.Int).Set(&paths[0]), nil
+}
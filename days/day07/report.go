@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Output formats for ExitReport.Render
+const (
+	ReportTable     = "table"     // counts with their share of the total
+	ReportHistogram = "histogram" // counts as bars scaled to the largest
+)
+
+// histogramWidth is the length of the longest bar in a histogram
+const histogramWidth = 50
+
+// SplitterHits is how many paths pass through a splitter
+type SplitterHits struct {
+	Pos  Position
+	Hits *big.Int
+}
+
+// ExitReport breaks the paths from S to the bottom down by the column they leave through
+// and the splitters they pass
+type ExitReport struct {
+	Total     *big.Int
+	Exits     []*big.Int     // paths leaving through each bottom column
+	Splitters []SplitterHits // every splitter in the grid, top to bottom and left to right
+}
+
+// ExitReport counts how many of the paths CountPaths counts leave through each column, and
+// how many pass through each splitter. A path that passes a splitter more than once, in
+// different directions, is counted each time.
+func (g *Grid) ExitReport() (*ExitReport, error) {
+	report := &ExitReport{Total: new(big.Int), Exits: make([]*big.Int, g.Width)}
+	for i := range report.Exits {
+		report.Exits[i] = new(big.Int)
+	}
+	splitters := make(map[Position]*big.Int)
+	for row := 0; row < g.Height; row++ {
+		for col := 0; col < g.Width; col++ {
+			if pos := (Position{Row: row, Col: col}); isSplitter(g.Get(pos)) {
+				report.Splitters = append(report.Splitters, SplitterHits{Pos: pos, Hits: new(big.Int)})
+				splitters[pos] = report.Splitters[len(report.Splitters)-1].Hits
+			}
+		}
+	}
+
+	sg, order, paths, err := g.pathCounts()
+	if err != nil {
+		return nil, err
+	}
+	if sg == nil {
+		return report, nil
+	}
+	report.Total.Set(&paths[0])
+
+	// ways[i] is the number of paths from S to state i. order has every state after the
+	// states it moves into, so walking it backwards finishes each state before its moves.
+	ways := make([]big.Int, len(sg.states))
+	ways[0].SetInt64(1)
+	var through big.Int
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+		b := sg.states[i]
+		if g.exited(b) {
+			report.Exits[b.Pos.Col].Set(&ways[i])
+			continue
+		}
+		if hits, ok := splitters[b.Dir.step(b.Pos)]; ok {
+			hits.Add(hits, through.Mul(&ways[i], &paths[i]))
+		}
+		for _, j := range sg.next[i] {
+			ways[j].Add(&ways[j], &ways[i])
+		}
+	}
+	return report, nil
+}
+
+// Render writes the exit and splitter counts to w in the given format
+func (r *ExitReport) Render(w io.Writer, format string) error {
+	columns := make([]string, len(r.Exits))
+	for i := range r.Exits {
+		columns[i] = fmt.Sprintf("column %d", i+1)
+	}
+	splitters := make([]string, len(r.Splitters))
+	hits := make([]*big.Int, len(r.Splitters))
+	for i, s := range r.Splitters {
+		splitters[i] = fmt.Sprintf("row %d, column %d", s.Pos.Row+1, s.Pos.Col+1)
+		hits[i] = s.Hits
+	}
+
+	write := r.writeTable
+	switch format {
+	case ReportTable:
+	case ReportHistogram:
+		write = writeHistogram
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+
+	fmt.Fprintf(w, "Exits by column:\n")
+	write(w, columns, r.Exits)
+	if len(r.Splitters) > 0 {
+		fmt.Fprintf(w, "\nSplitter hits:\n")
+		write(w, splitters, hits)
+	}
+	return nil
+}
+
+// writeTable writes one line per count with its share of the total paths
+func (r *ExitReport) writeTable(w io.Writer, labels []string, counts []*big.Int) {
+	labelWidth, countWidth := maxLen(labels), 0
+	for _, c := range counts {
+		countWidth = max(countWidth, len(c.String()))
+	}
+	for i, c := range counts {
+		fmt.Fprintf(w, "  %-*s  %*s  %6.2f%%\n", labelWidth, labels[i], countWidth, c, 100*ratio(c, r.Total))
+	}
+}
+
+// writeHistogram writes one bar per count, scaled so the largest count fills histogramWidth
+func writeHistogram(w io.Writer, labels []string, counts []*big.Int) {
+	labelWidth := maxLen(labels)
+	largest := new(big.Int)
+	for _, c := range counts {
+		if c.Cmp(largest) > 0 {
+			largest = c
+		}
+	}
+	for i, c := range counts {
+		bar := int(ratio(c, largest)*histogramWidth + 0.5)
+		if bar == 0 && c.Sign() > 0 {
+			bar = 1 // show every count that isn't zero
+		}
+		fmt.Fprintf(w, "  %-*s |%-*s %s\n", labelWidth, labels[i], histogramWidth, strings.Repeat("#", bar), c)
+	}
+}
+
+// ratio returns n/d as a float64, or 0 when d is zero
+func ratio(n, d *big.Int) float64 {
+	if d.Sign() == 0 {
+		return 0
+	}
+	f, _ := new(big.Rat).SetFrac(n, d).Float64()
+	return f
+}
+
+func maxLen(labels []string) int {
+	width := 0
+	for _, l := range labels {
+		width = max(width, len(l))
+	}
+	return width
+}
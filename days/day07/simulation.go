@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Simulation runs the beams over a Grid without writing into it, so the grid can be
+// simulated again or passed to CountPaths afterwards
+type Simulation struct {
+	grid        *Grid
+	lit         [][]uint8 // per cell, a bit for each direction a beam has passed through in
+	active      []BeamState
+	Round       int
+	TotalSplits int
+}
+
+// Frame is the state after a round: the grid with the beams drawn in, the split counts and
+// the beams the round added. Round 0 is the input grid.
+type Frame struct {
+	Round       int
+	RoundSplits int
+	TotalSplits int
+	Added       []Position
+	Cells       [][]Cell
+}
+
+// NewSimulation starts a beam moving down from the grid's start position; without one it
+// is already done
+func (g *Grid) NewSimulation() *Simulation {
+	s := &Simulation{grid: g, lit: make([][]uint8, g.Height)}
+	for i := range s.lit {
+		s.lit[i] = make([]uint8, g.Width)
+	}
+	if start := g.FindStart(); start != nil {
+		s.active = []BeamState{{Pos: *start, Dir: Down}}
+	}
+	return s
+}
+
+// Done reports whether every beam has left the grid or stopped
+func (s *Simulation) Done() bool {
+	return len(s.active) == 0
+}
+
+// Get returns the cell at pos with the beams drawn into empty cells: '|' for beams moving
+// up or down, '-' for beams moving sideways and '+' where both have passed
+func (s *Simulation) Get(pos Position) Cell {
+	cell := s.grid.Get(pos)
+	if cell != Empty || !s.grid.IsInBounds(pos) {
+		return cell
+	}
+
+	const vertical, sideways = 1<<Down | 1<<Up, 1<<Left | 1<<Right
+	switch lit := s.lit[pos.Row][pos.Col]; {
+	case lit&vertical != 0 && lit&sideways != 0:
+		return CrossBeam
+	case lit&vertical != 0:
+		return Beam
+	case lit&sideways != 0:
+		return SideBeam
+	}
+	return Empty
+}
+
+// Step moves every active beam one cell and returns the round's frame. A beam stops when
+// it would pass through a cell in a direction a beam has already taken there, so beams
+// that merge are only followed once and beams that loop stop after one lap.
+func (s *Simulation) Step() Frame {
+	s.Round++
+	roundSplits := 0
+	var nextBeams []BeamState
+	var added []Position
+
+	emptyCell := func(c Cell) bool { return c == Empty }
+	light := func(b BeamState) {
+		if s.grid.exited(b) || s.lit[b.Pos.Row][b.Pos.Col]&(1<<b.Dir) != 0 {
+			return
+		}
+		s.lit[b.Pos.Row][b.Pos.Col] |= 1 << b.Dir
+		nextBeams = append(nextBeams, b)
+		added = append(added, b.Pos)
+	}
+
+	for _, beam := range s.active {
+		if isSplitter(s.grid.Get(beam.Dir.step(beam.Pos))) {
+			roundSplits++
+		}
+		s.grid.moves(beam, emptyCell, light)
+	}
+
+	s.TotalSplits += roundSplits
+	s.active = nextBeams
+	frame := s.Frame()
+	frame.RoundSplits = roundSplits
+	frame.Added = added
+	return frame
+}
+
+// Frame returns the current state as a frame
+func (s *Simulation) Frame() Frame {
+	cells := make([][]Cell, s.grid.Height)
+	for row := range cells {
+		cells[row] = make([]Cell, s.grid.Width)
+		for col := range cells[row] {
+			cells[row][col] = s.Get(Position{Row: row, Col: col})
+		}
+	}
+	return Frame{Round: s.Round, TotalSplits: s.TotalSplits, Cells: cells}
+}
+
+// Frames runs a simulation to the end, returning the input frame followed by one frame per
+// round
+func (g *Grid) Frames() []Frame {
+	s := g.NewSimulation()
+	frames := []Frame{s.Frame()}
+	for !s.Done() {
+		frames = append(frames, s.Step())
+	}
+	return frames
+}
+
+// String returns the frame's cells, one line per row
+func (f Frame) String() string {
+	var sb strings.Builder
+	for _, row := range f.Cells {
+		sb.WriteString(string(row))
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// WriteFramesText writes every frame in the format splits mode prints
+func WriteFramesText(w io.Writer, grid *Grid, frames []Frame) error {
+	for _, f := range frames {
+		if f.Round == 0 {
+			fmt.Fprintf(w, "=== Initial State ===\n%s", f)
+			continue
+		}
+		fmt.Fprintf(w, "\n=== Round %d ===\n", f.Round)
+		fmt.Fprintf(w, "Splits this round: %d\n", f.RoundSplits)
+		fmt.Fprintf(w, "Total splits: %d\n", f.TotalSplits)
+		fmt.Fprint(w, f)
+	}
+	_, err := fmt.Fprintf(w, "\n=== Finished after %d rounds ===\n", len(frames)-1)
+	return err
+}
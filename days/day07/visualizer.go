@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"os"
+)
+
+// Visualization colors for each kind of cell
+var (
+	emptyColor = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	startColor = color.RGBA{0x2e, 0x9e, 0x44, 0xff}
+	splitColor = color.RGBA{0x40, 0x40, 0x40, 0xff}
+	beamColor  = color.RGBA{0xe0, 0x40, 0x30, 0xff}
+	otherColor = color.RGBA{0xc8, 0xc8, 0xc8, 0xff}
+)
+
+// frameDelay is the GIF delay between rounds in 100ths of a second
+const frameDelay = 20
+
+// cellScale picks a pixel size per cell that keeps the image around 800 pixels
+func cellScale(grid *Grid) int {
+	return max(1, min(10, 800/max(grid.Width, grid.Height, 1)))
+}
+
+// cellColor returns the palette index of a cell in cellPalette
+func cellColor(c Cell) uint8 {
+	switch c {
+	case Empty:
+		return 0
+	case Start:
+		return 1
+	case Split, ThreeWay:
+		return 2
+	case Beam, SideBeam, CrossBeam:
+		return 3
+	}
+	return 4
+}
+
+var cellPalette = color.Palette{emptyColor, startColor, splitColor, beamColor, otherColor}
+
+// WriteFramesGIF writes an animated GIF with one image per frame
+func WriteFramesGIF(w io.Writer, grid *Grid, frames []Frame) error {
+	scale := cellScale(grid)
+	anim := &gif.GIF{}
+	for i, f := range frames {
+		img := image.NewPaletted(image.Rect(0, 0, grid.Width*scale, grid.Height*scale), cellPalette)
+		for row, cells := range f.Cells {
+			for col, c := range cells {
+				idx := cellColor(c)
+				if idx == 0 {
+					continue
+				}
+				for py := row * scale; py < (row+1)*scale; py++ {
+					for px := col * scale; px < (col+1)*scale; px++ {
+						img.SetColorIndex(px, py, idx)
+					}
+				}
+			}
+		}
+
+		delay := frameDelay
+		if i == len(frames)-1 {
+			delay = frameDelay * 10 // linger on the final state
+		}
+		anim.Image = append(anim.Image, img)
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
+
+// WriteFramesSVG writes an SVG of the input grid with one layer (<g id="round-N">) per
+// round holding the beams it added. Each layer is hidden at first and revealed in turn.
+func WriteFramesSVG(w io.Writer, grid *Grid, frames []Frame) error {
+	bw := bufio.NewWriter(w)
+	scale := cellScale(grid)
+	seconds := float64(frameDelay) / 100
+
+	fmt.Fprintf(bw, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" shape-rendering=\"crispEdges\">\n",
+		grid.Width*scale, grid.Height*scale, grid.Width, grid.Height)
+	fmt.Fprintf(bw, "  <rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", hexColor(emptyColor))
+
+	// Base layer: the input grid
+	fmt.Fprintf(bw, "  <g id=\"input\">\n")
+	for row := 0; row < grid.Height; row++ {
+		for col := 0; col < grid.Width; col++ {
+			idx := cellColor(grid.Cells[row][col])
+			if idx == 0 {
+				continue
+			}
+			fmt.Fprintf(bw, "    <rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\" fill=\"%s\"/>\n", col, row, hexColor(cellPalette[idx].(color.RGBA)))
+		}
+	}
+	fmt.Fprintf(bw, "  </g>\n")
+
+	// One layer per round, revealed in order
+	for _, f := range frames {
+		if f.Round == 0 {
+			continue
+		}
+		fmt.Fprintf(bw, "  <g id=\"round-%d\" fill=\"%s\" opacity=\"0\">\n", f.Round, hexColor(beamColor))
+		fmt.Fprintf(bw, "    <title>Round %d: %d splits, %d beams added</title>\n", f.Round, f.RoundSplits, len(f.Added))
+		fmt.Fprintf(bw, "    <set attributeName=\"opacity\" to=\"1\" begin=\"%.2fs\" fill=\"freeze\"/>\n", float64(f.Round)*seconds)
+		for _, pos := range f.Added {
+			fmt.Fprintf(bw, "    <rect x=\"%d\" y=\"%d\" width=\"1\" height=\"1\"/>\n", pos.Col, pos.Row)
+		}
+		fmt.Fprintf(bw, "  </g>\n")
+	}
+
+	fmt.Fprintf(bw, "</svg>\n")
+	return bw.Flush()
+}
+
+// writeFramesFile writes frames to path with the given writer function
+func writeFramesFile(path string, write func(io.Writer, *Grid, []Frame) error, grid *Grid, frames []Frame) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, grid, frames); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}